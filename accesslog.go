@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type AccessLog struct {
+	config AccessLogConfig
+	log    Logger
+}
+
+// AccessLogConfig reads the requests appended to Path since the previous run.
+// Requests are grouped by the first URI pattern they match ("other" if none),
+// the method and the status class, e.g. accesslog.users.GET.2xx.reqtime.p99.
+type AccessLogConfig struct {
+	Path              string                 `toml:"path"`
+	Format            string                 `toml:"format"`
+	StatePath         string                 `toml:"state_path"`
+	ReadFromStart     bool                   `toml:"read_from_start"`
+	Prefix            string                 `toml:"prefix"`
+	URIField          string                 `toml:"uri_field"`
+	MethodField       string                 `toml:"method_field"`
+	StatusField       string                 `toml:"status_field"`
+	ReqtimeField      string                 `toml:"reqtime_field"`
+	UpstreamTimeField string                 `toml:"upstream_time_field"`
+	Percentiles       []float64              `toml:"percentiles"`
+	Groups            []AccessLogGroupConfig `toml:"groups"`
+	TimestampConfig
+}
+
+// AccessLogGroupConfig names the URIs matching Pattern (query strings are stripped), e.g. "^/users/[0-9]+$".
+type AccessLogGroupConfig struct {
+	Name    string `toml:"name"`
+	Pattern string `toml:"pattern"`
+	pattern *regexp.Regexp
+}
+
+type accessLogRequest struct {
+	group  string
+	method string
+	status string
+	times  map[string]float64
+}
+
+func NewAccessLog(alConfig AccessLogConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var al *AccessLog
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return al, err
+	}
+
+	var config AccessLogConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return al, err
+	}
+
+	if alConfig.Path != "" {
+		config.Path = alConfig.Path
+	}
+
+	if config.Path == "" {
+		return al, errors.New("path is required")
+	}
+
+	if alConfig.StatePath != "" {
+		config.StatePath = alConfig.StatePath
+	} else if config.StatePath == "" {
+		config.StatePath = DefaultStatePath("accesslog")
+	}
+
+	switch config.Format {
+	case "":
+		config.Format = "ltsv"
+	case "ltsv", "json":
+	default:
+		return al, fmt.Errorf("Invalid format: %s", config.Format)
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = "accesslog"
+	}
+
+	for _, f := range []struct {
+		field *string
+		def   string
+	}{
+		{&config.URIField, "uri"},
+		{&config.MethodField, "method"},
+		{&config.StatusField, "status"},
+		{&config.ReqtimeField, "reqtime"},
+		{&config.UpstreamTimeField, "upstream_response_time"},
+	} {
+		if *f.field == "" {
+			*f.field = f.def
+		}
+	}
+
+	if config.Percentiles == nil {
+		config.Percentiles = []float64{50, 90, 99}
+	}
+
+	for _, p := range config.Percentiles {
+		if p <= 0 || p > 100 {
+			return al, fmt.Errorf("Invalid percentile: %v", p)
+		}
+	}
+
+	for i := range config.Groups {
+		g := &config.Groups[i]
+
+		if g.Name == "" || g.Pattern == "" {
+			return al, fmt.Errorf("groups[%d]: name and pattern are required", i)
+		}
+
+		g.pattern, err = regexp.Compile(g.Pattern)
+		if err != nil {
+			return al, err
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return al, err
+	}
+
+	al = &AccessLog{
+		config: config,
+		log:    log,
+	}
+
+	return al, err
+}
+
+func parseLTSV(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, f := range strings.Split(line, "\t") {
+		kv := strings.SplitN(f, ":", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+
+	return fields
+}
+
+func parseJSONLine(line string) (map[string]string, error) {
+	var data map[string]interface{}
+	fields := make(map[string]string)
+
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return fields, err
+	}
+
+	for k, v := range data {
+		if v != nil {
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+
+	return fields, nil
+}
+
+// parseResponseTime parses a response time, the times of several upstreams ("0.010, 0.020" or
+// "0.010 : 0.020") are summed up, "-" is not a time.
+func parseResponseTime(s string) (float64, bool) {
+	var total float64
+	var ok bool
+
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ':' || r == ' ' }) {
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			continue
+		}
+		total += v
+		ok = true
+	}
+
+	return total, ok
+}
+
+func (al *AccessLog) group(uri string) string {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+
+	for _, g := range al.config.Groups {
+		if g.pattern.MatchString(uri) {
+			return g.Name
+		}
+	}
+
+	return "other"
+}
+
+func (al *AccessLog) parse(line string) (accessLogRequest, error) {
+	var err error
+	var fields map[string]string
+	var req accessLogRequest
+
+	if al.config.Format == "json" {
+		fields, err = parseJSONLine(line)
+		if err != nil {
+			return req, err
+		}
+	} else {
+		fields = parseLTSV(line)
+	}
+
+	status := fields[al.config.StatusField]
+	if len(status) != 3 {
+		return req, fmt.Errorf("Invalid status: %s", line)
+	}
+
+	req.group = sanitizeName(al.group(fields[al.config.URIField]))
+	req.method = sanitizeName(fields[al.config.MethodField])
+	if req.method == "" {
+		req.method = "none"
+	}
+	req.status = fmt.Sprintf("%cxx", status[0])
+
+	req.times = make(map[string]float64)
+	for _, f := range []string{al.config.ReqtimeField, al.config.UpstreamTimeField} {
+		if v, ok := parseResponseTime(fields[f]); ok {
+			req.times[sanitizeName(f)] = v
+		}
+	}
+
+	return req, err
+}
+
+func (al *AccessLog) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	state, err := NewState(al.config.StatePath)
+	if err != nil {
+		return metrics, err
+	}
+	defer state.Close()
+
+	counts := make(map[string]float64)
+	times := make(map[string]map[string][]float64)
+
+	err = TailFile(&state, al.config.Path, al.config.ReadFromStart, func(line string) {
+		req, err := al.parse(line)
+		if err != nil {
+			al.log.Debug(err)
+			return
+		}
+
+		key := fmt.Sprintf("%s.%s.%s", req.group, req.method, req.status)
+		counts[key]++
+
+		if times[key] == nil {
+			times[key] = make(map[string][]float64)
+		}
+		for f, v := range req.times {
+			times[key][f] = append(times[key][f], v)
+		}
+	})
+	metrics = append(metrics, SectionMetrics(al.config.Prefix, err, now)...)
+	if err != nil {
+		return al.config.Apply(metrics, now), err
+	}
+
+	stats := make(map[string]float64)
+	for key, count := range counts {
+		stats[fmt.Sprintf("%s.count", key)] = count
+
+		for f, values := range times[key] {
+			sort.Float64s(values)
+
+			var sum float64
+			for _, v := range values {
+				sum += v
+			}
+
+			name := fmt.Sprintf("%s.%s", key, f)
+			stats[fmt.Sprintf("%s.min", name)] = values[0]
+			stats[fmt.Sprintf("%s.max", name)] = values[len(values)-1]
+			stats[fmt.Sprintf("%s.avg", name)] = sum / float64(len(values))
+			for _, p := range al.config.Percentiles {
+				stats[fmt.Sprintf("%s.%s", name, quantileName(p/100))] = Percentile(values, p)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(stats) {
+		metrics = append(metrics, Metric{
+			Name:  fmt.Sprintf("%s.%s", al.config.Prefix, key),
+			Value: stats[key],
+			Time:  now,
+		})
+	}
+
+	return al.config.Apply(metrics, now), err
+}
+
+func (al *AccessLog) Teardown() {
+
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"net"
+	"text/template"
+	"time"
+)
+
+const defaultCertNameTemplate = `{{sanitize .Source}}.{{.Index}}`
+
+type Cert struct {
+	config   CertConfig
+	nameTmpl *template.Template
+	log      Logger
+}
+
+// CertConfig reports the days until expiry of every certificate found in Files (glob patterns,
+// "**" matches any number of directories) and presented by Endpoints (host:port).
+// Certificates are named cert.<NameTemplate>.days, the template gets the Source (path or endpoint),
+// the Index in the chain (0 is the leaf) and the subject CN, e.g. "{{sanitize .CN}}".
+type CertConfig struct {
+	Files        []string `toml:"files"`
+	Endpoints    []string `toml:"endpoints"`
+	Timeout      int      `toml:"timeout"`
+	Concurrency  int      `toml:"concurrency"`
+	NameTemplate string   `toml:"name_template"`
+	TimestampConfig
+}
+
+type certName struct {
+	Source string
+	Index  int
+	CN     string
+}
+
+func NewCert(certConfig CertConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var c *Cert
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return c, err
+	}
+
+	var config CertConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return c, err
+	}
+
+	if certConfig.Timeout > 0 {
+		config.Timeout = certConfig.Timeout
+	} else if config.Timeout == 0 {
+		config.Timeout = 10
+	}
+
+	if certConfig.Concurrency > 0 {
+		config.Concurrency = certConfig.Concurrency
+	}
+
+	if config.NameTemplate == "" {
+		config.NameTemplate = defaultCertNameTemplate
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return c, err
+	}
+
+	var tmpl *template.Template
+	tmpl, err = NewNameTemplate(config.NameTemplate)
+	if err != nil {
+		return c, err
+	}
+
+	c = &Cert{
+		config:   config,
+		nameTmpl: tmpl,
+		log:      log,
+	}
+
+	return c, err
+}
+
+// readCertFile returns the certificates of a PEM file, other blocks such as keys are skipped.
+func readCertFile(path string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return certs, err
+	}
+
+	for {
+		var block *pem.Block
+		block, buf = pem.Decode(buf)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return certs, err
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// readEndpointCerts returns the chain presented by endpoint, it is not verified so that
+// expired or self-signed certificates are reported as well.
+func (c *Cert) readEndpointCerts(endpoint string) ([]*x509.Certificate, error) {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: time.Duration(c.config.Timeout) * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", endpoint, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates, nil
+}
+
+func (c *Cert) name(source string, index int, cert *x509.Certificate) (string, error) {
+	var buf bytes.Buffer
+
+	err := c.nameTmpl.Execute(&buf, certName{
+		Source: source,
+		Index:  index,
+		CN:     cert.Subject.CommonName,
+	})
+
+	return buf.String(), err
+}
+
+func (c *Cert) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	var errs MultiError
+	var sources []string
+	var chains [][]*x509.Certificate
+	for _, pattern := range c.config.Files {
+		paths, err := Glob(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", pattern, err))
+			continue
+		}
+
+		for _, path := range paths {
+			certs, err := readCertFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s", path, err))
+				continue
+			}
+
+			if len(certs) > 0 {
+				sources = append(sources, path)
+				chains = append(chains, certs)
+			}
+		}
+	}
+
+	endpointChains := make([][]*x509.Certificate, len(c.config.Endpoints))
+	endpointErrs := make([]error, len(c.config.Endpoints))
+	RunParallel(c.config.Concurrency, len(c.config.Endpoints), func(i int) error {
+		endpointChains[i], endpointErrs[i] = c.readEndpointCerts(c.config.Endpoints[i])
+		return endpointErrs[i]
+	})
+
+	for i, endpoint := range c.config.Endpoints {
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("cert.%s", sanitizeName(endpoint)), endpointErrs[i], now)...)
+		if endpointErrs[i] != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", endpoint, endpointErrs[i]))
+			continue
+		}
+
+		sources = append(sources, endpoint)
+		chains = append(chains, endpointChains[i])
+	}
+
+	for i, source := range sources {
+		for j, cert := range chains[i] {
+			name, err := c.name(source, j, cert)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			metrics = append(metrics, Metric{
+				Name:  fmt.Sprintf("cert.%s.days", name),
+				Value: cert.NotAfter.Sub(now).Hours() / 24,
+				Time:  now,
+			})
+		}
+	}
+
+	return c.config.Apply(metrics, now), errs.ErrorOrNil()
+}
+
+func (c *Cert) Teardown() {
+
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Check struct {
+	config CheckConfig
+	log    Logger
+}
+
+type CheckConfig struct {
+	Timeout     int                  `toml:"timeout"`
+	Concurrency int                  `toml:"concurrency"`
+	Checks      []CheckMetricsConfig `toml:"checks"`
+	TimestampConfig
+}
+
+// CheckMetricsConfig checks the endpoint in Name, an http(s):// URL or a host:port (optionally tcp://) to connect to.
+// Metrics are named <prefix>.<alias>.<stat>, the prefix defaults to "check" and the alias to the sanitized Name.
+// An HTTP check succeeds if the status is one of ExpectedStatus (any 2xx or 3xx by default) and the body
+// matches BodyRegex, redirects are only followed with FollowRedirects. body_match is 0 without BodyRegex.
+// A TCP check connects to the resolved addresses in turn until one succeeds.
+type CheckMetricsConfig struct {
+	Method          string            `toml:"method"`
+	Headers         map[string]string `toml:"headers"`
+	ExpectedStatus  []int             `toml:"expected_status"`
+	BodyRegex       string            `toml:"body_regex"`
+	FollowRedirects bool              `toml:"follow_redirects"`
+	TLS             bool              `toml:"tls"`
+	MetricsConfig
+	TLSConfig
+	bodyRegex *regexp.Regexp
+	client    *http.Client
+	tlsConfig *tls.Config
+}
+
+func NewCheck(checkConfig CheckConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var c *Check
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return c, err
+	}
+
+	var config CheckConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return c, err
+	}
+
+	if checkConfig.Timeout > 0 {
+		config.Timeout = checkConfig.Timeout
+	} else if config.Timeout == 0 {
+		config.Timeout = 10
+	}
+
+	if checkConfig.Concurrency > 0 {
+		config.Concurrency = checkConfig.Concurrency
+	}
+
+	for i := range config.Checks {
+		cc := &config.Checks[i]
+
+		if cc.Name == "" {
+			return c, fmt.Errorf("checks[%d]: name is required", i)
+		}
+
+		if cc.Alias == "" {
+			cc.Alias = sanitizeName(cc.Name)
+		}
+
+		if cc.Prefix == "" {
+			cc.Prefix = "check"
+		}
+
+		if cc.Method == "" {
+			cc.Method = "GET"
+		}
+
+		if cc.BodyRegex != "" {
+			cc.bodyRegex, err = regexp.Compile(cc.BodyRegex)
+			if err != nil {
+				return c, err
+			}
+		}
+
+		cc.tlsConfig, err = cc.TLSConfig.Load()
+		if err != nil {
+			return c, err
+		}
+
+		cc.client = &http.Client{
+			Timeout: time.Duration(config.Timeout) * time.Second,
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				TLSClientConfig:   cc.tlsConfig,
+				DisableKeepAlives: true,
+			},
+		}
+
+		if !cc.FollowRedirects {
+			cc.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return c, err
+	}
+
+	c = &Check{
+		config: config,
+		log:    log,
+	}
+
+	return c, err
+}
+
+func (cc *CheckMetricsConfig) isHTTP() bool {
+	return strings.HasPrefix(cc.Name, "http://") || strings.HasPrefix(cc.Name, "https://")
+}
+
+func certDays(certs []*x509.Certificate, now time.Time) (float64, bool) {
+	if len(certs) == 0 {
+		return 0, false
+	}
+
+	return certs[0].NotAfter.Sub(now).Hours() / 24, true
+}
+
+func (cc *CheckMetricsConfig) checkHTTP(stats map[string]float64) error {
+	var start, dnsStart, tlsStart time.Time
+	stats["body_match"] = 0
+
+	req, err := http.NewRequest(cc.Method, cc.Name, nil)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range cc.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	// connects to several addresses may run in parallel and finish after the request,
+	// so every callback takes the lock and connects are timed per address
+	var mu sync.Mutex
+	timings := make(map[string]float64)
+	connectStarts := make(map[string]time.Time)
+	mark := func(t *time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		*t = time.Now()
+	}
+	set := func(key string, since *time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		timings[key] = time.Since(*since).Seconds()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { set("dns_seconds", &dnsStart) },
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStarts[network+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if s, ok := connectStarts[network+addr]; ok && err == nil {
+				timings["connect_seconds"] = time.Since(s).Seconds()
+			}
+		},
+		TLSHandshakeStart: func() { mark(&tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				set("tls_seconds", &tlsStart)
+			}
+		},
+		GotFirstResponseByte: func() { set("first_byte_seconds", &start) },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start = time.Now()
+	resp, err := cc.client.Do(req)
+
+	mu.Lock()
+	for k, v := range timings {
+		stats[k] = v
+	}
+	mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	stats["total_seconds"] = time.Since(start).Seconds()
+	stats["status_code"] = float64(resp.StatusCode)
+	if err != nil {
+		return err
+	}
+
+	if resp.TLS != nil {
+		if days, ok := certDays(resp.TLS.PeerCertificates, time.Now()); ok {
+			stats["cert_days"] = days
+		}
+	}
+
+	if len(cc.ExpectedStatus) > 0 {
+		var found bool
+		for _, s := range cc.ExpectedStatus {
+			found = found || s == resp.StatusCode
+		}
+
+		if !found {
+			return fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	if cc.bodyRegex != nil {
+		if !cc.bodyRegex.Match(body) {
+			return fmt.Errorf("body does not match %s", cc.BodyRegex)
+		}
+		stats["body_match"] = 1
+	}
+
+	return nil
+}
+
+func (cc *CheckMetricsConfig) checkTCP(stats map[string]float64, timeout time.Duration) error {
+	address := strings.TrimPrefix(cc.Name, "tcp://")
+	if u, err := url.Parse(cc.Name); err == nil && u.Scheme == "tcp" {
+		address = u.Host
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	stats["dns_seconds"] = time.Since(start).Seconds()
+
+	var dialer net.Dialer
+	var conn net.Conn
+	for _, addr := range addrs {
+		connectStart := time.Now()
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, port))
+		if err == nil {
+			stats["connect_seconds"] = time.Since(connectStart).Seconds()
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if cc.TLS {
+		c := cc.tlsConfig.Clone()
+		if c.ServerName == "" {
+			c.ServerName = host
+		}
+
+		tlsStart := time.Now()
+		tlsConn := tls.Client(conn, c)
+		tlsConn.SetDeadline(time.Now().Add(timeout))
+		err = tlsConn.Handshake()
+		if err != nil {
+			return err
+		}
+		stats["tls_seconds"] = time.Since(tlsStart).Seconds()
+
+		if days, ok := certDays(tlsConn.ConnectionState().PeerCertificates, time.Now()); ok {
+			stats["cert_days"] = days
+		}
+	}
+
+	stats["total_seconds"] = time.Since(start).Seconds()
+
+	return nil
+}
+
+func (c *Check) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+
+	results := make([]map[string]float64, len(c.config.Checks))
+	RunParallel(c.config.Concurrency, len(c.config.Checks), func(i int) error {
+		cc := &c.config.Checks[i]
+		stats := make(map[string]float64)
+
+		var err error
+		if cc.isHTTP() {
+			err = cc.checkHTTP(stats)
+		} else {
+			err = cc.checkTCP(stats, time.Duration(c.config.Timeout)*time.Second)
+		}
+
+		// a failed check is a result, not an error of the input
+		stats["success"] = 1
+		if err != nil {
+			c.log.Debug(fmt.Sprintf("%s: %s", cc.Name, err))
+			stats["success"] = 0
+		}
+
+		results[i] = stats
+		return nil
+	})
+
+	metrics := make([]Metric, 0)
+	for i, cc := range c.config.Checks {
+		for _, key := range sortedKeys(results[i]) {
+			metrics = append(metrics, Metric{
+				Name:  fmt.Sprintf("%s.%s.%s", cc.Prefix, cc.Alias, key),
+				Value: results[i][key],
+				Time:  now,
+			})
+		}
+	}
+
+	return c.config.Apply(metrics, now), nil
+}
+
+func (c *Check) Teardown() {
+
+}
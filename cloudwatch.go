@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"github.com/BurntSushi/toml"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -23,8 +24,9 @@ type CloudWatchConfig struct {
 	Token              string                    `toml:"token"`
 	Profile            string                    `toml:"profile"`
 	Credentials        string                    `toml:"credentials"`
-	Timezone           string                    `toml:"timezone"`
+	Concurrency        int                       `toml:"concurrency"`
 	Metrics            []CloudWatchMetricsConfig `toml:"metrics"`
+	TimestampConfig
 }
 
 type CloudWatchMetricsConfig struct {
@@ -99,8 +101,13 @@ func NewCloudWatch(cwConfig CloudWatchConfig, filename string, log Logger) (Inpu
 		c.Credentials = cwConfig.Credentials
 	}
 
-	if cwConfig.Timezone != "" {
-		c.Timezone = cwConfig.Timezone
+	if cwConfig.Concurrency > 0 {
+		c.Concurrency = cwConfig.Concurrency
+	}
+
+	err = c.TimestampConfig.Setup()
+	if err != nil {
+		return cw, err
 	}
 
 	sess := session.New()
@@ -177,10 +184,7 @@ func (c *CloudWatch) createGetMetricStatisticsInput(msi MetricStatisticsInput) *
 		input.Period = aws.Int64(period)
 	}
 
-	now, err := FixedTimezone(time.Now(), c.config.Timezone)
-	if err != nil {
-		c.log.Debug(err)
-	}
+	now := time.Now().UTC()
 
 	if !msi.StartTime.IsZero() {
 		input.StartTime = aws.Time(msi.StartTime)
@@ -224,13 +228,9 @@ func (c *CloudWatch) fetchDatapoint(output *cloudwatch.GetMetricStatisticsOutput
 
 func (c *CloudWatch) latestDatapoint(cwDatapoints []*cloudwatch.Datapoint, stat string) Datapoint {
 	if len(cwDatapoints) == 0 {
-		now, err := FixedTimezone(time.Now(), c.config.Timezone)
-		if err != nil {
-			c.log.Debug(err)
-		}
 		return Datapoint{
 			Value:     0,
-			Timestamp: now,
+			Timestamp: ScheduledNow(),
 		}
 	}
 
@@ -251,13 +251,9 @@ func (c *CloudWatch) latestDatapoint(cwDatapoints []*cloudwatch.Datapoint, stat
 			value = *o.Sum
 		}
 
-		now, err := FixedTimezone(*o.Timestamp, c.config.Timezone)
-		if err != nil {
-			c.log.Debug(err)
-		}
 		datapoints = append(datapoints, Datapoint{
 			Value:     value,
-			Timestamp: now,
+			Timestamp: o.Timestamp.UTC(),
 		})
 	}
 
@@ -266,47 +262,94 @@ func (c *CloudWatch) latestDatapoint(cwDatapoints []*cloudwatch.Datapoint, stat
 	return datapoints[0]
 }
 
+type cloudWatchJob struct {
+	config CloudWatchMetricsConfig
+	name   string
+}
+
+func (c *CloudWatch) fetchMetric(job cloudWatchJob) (Metric, bool, error) {
+	m := job.config
+	msi := c.createGetMetricStatisticsInput(MetricStatisticsInput{
+		Dimensions: createDimensions(m.Dimensions),
+		MetricName: job.name,
+		Namespace:  m.Namespace,
+		Statistics: createStatistics(m.Statistics),
+		Period:     m.Period,
+	})
+
+	resp, err := c.getMetricStatistics(msi)
+
+	if err != nil {
+		return Metric{}, false, fmt.Errorf("%s/%s: %s", m.Namespace, job.name, err)
+	}
+
+	datapoint := c.fetchDatapoint(resp, m.Statistics)
+
+	if (Datapoint{}) == datapoint {
+		return Metric{}, false, nil
+	}
+
+	return Metric{
+		Name:  m.CreateName(job.name),
+		Time:  datapoint.Timestamp,
+		Value: m.CalcValue(datapoint.Value),
+	}, true, nil
+}
+
 func (c *CloudWatch) FetchMetrics() ([]Metric, error) {
 	var err error
 	var metrics []Metric
 
-	metrics = make([]Metric, 0, len(c.config.Metrics))
+	now := ScheduledNow()
 
+	jobs := make([]cloudWatchJob, 0, len(c.config.Metrics))
 	for _, m := range c.config.Metrics {
 		names := m.SplitName()
 		for _, n := range names {
-			msi := c.createGetMetricStatisticsInput(MetricStatisticsInput{
-				Dimensions: createDimensions(m.Dimensions),
-				MetricName: n,
-				Namespace:  m.Namespace,
-				Statistics: createStatistics(m.Statistics),
-				Period:     m.Period,
+			jobs = append(jobs, cloudWatchJob{
+				config: m,
+				name:   n,
 			})
+		}
+	}
 
-			resp, err := c.getMetricStatistics(msi)
-
-			if err != nil {
-				return metrics, err
-			}
-
-			datapoint := c.fetchDatapoint(resp, m.Statistics)
-
-			if (Datapoint{}) == datapoint {
-				continue
-			}
+	results := make([]Metric, len(jobs))
+	found := make([]bool, len(jobs))
+	errs := make([]error, len(jobs))
+
+	err = RunParallel(c.config.Concurrency, len(jobs), func(i int) error {
+		results[i], found[i], errs[i] = c.fetchMetric(jobs[i])
+		return errs[i]
+	})
+
+	// every namespace is a section, e.g. cloudwatch.AWS_EC2.up
+	var namespaces []string
+	nsErrs := make(map[string]MultiError)
+	metrics = make([]Metric, 0, len(jobs)+2*len(c.config.Metrics)+2)
+	for i, m := range results {
+		ns := jobs[i].config.Namespace
+		if _, ok := nsErrs[ns]; !ok {
+			namespaces = append(namespaces, ns)
+			nsErrs[ns] = MultiError{}
+		}
 
-			name := m.CreateName(n)
-			value := m.CalcValue(datapoint.Value)
+		if errs[i] != nil {
+			nsErrs[ns] = append(nsErrs[ns], errs[i])
+		}
 
-			metrics = append(metrics, Metric{
-				Name:  name,
-				Time:  datapoint.Timestamp,
-				Value: value,
-			})
+		if found[i] {
+			metrics = append(metrics, m)
 		}
 	}
 
-	return metrics, err
+	for _, ns := range namespaces {
+		name := fmt.Sprintf("cloudwatch.%s", sanitizeName(ns))
+		metrics = append(metrics, SectionMetrics(name, nsErrs[ns].ErrorOrNil(), now)...)
+	}
+
+	metrics = append(metrics, SectionMetrics("cloudwatch", err, now)...)
+
+	return c.config.Apply(metrics, now), err
 }
 
 func (c *CloudWatch) Teardown() {
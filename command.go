@@ -15,8 +15,8 @@ type Command struct {
 }
 
 type CommandConfig struct {
-	Command  string `toml:"command"`
-	Timezone string `toml:"timezone"`
+	Command string `toml:"command"`
+	TimestampConfig
 }
 
 func NewCommand(cmdConfig CommandConfig, filename string, log Logger) (Input, error) {
@@ -40,8 +40,9 @@ func NewCommand(cmdConfig CommandConfig, filename string, log Logger) (Input, er
 		config.Command = cmdConfig.Command
 	}
 
-	if cmdConfig.Timezone != "" {
-		config.Timezone = cmdConfig.Timezone
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return c, err
 	}
 
 	c = &Command{
@@ -52,9 +53,8 @@ func NewCommand(cmdConfig CommandConfig, filename string, log Logger) (Input, er
 	return c, err
 }
 
-func (cmd *Command) runCommand() (map[string]Stat, error) {
+func (cmd *Command) runCommand(now time.Time) (map[string]Stat, error) {
 	var err error
-	var now time.Time
 	var data map[string]Stat
 	commands := strings.Fields(cmd.config.Command)
 
@@ -84,6 +84,7 @@ func (cmd *Command) runCommand() (map[string]Stat, error) {
 			continue
 		}
 
+		t := now
 		if len(lines) > 2 {
 			var timestamp int64
 			timestamp, err = strconv.ParseInt(lines[2], 10, 64)
@@ -91,20 +92,12 @@ func (cmd *Command) runCommand() (map[string]Stat, error) {
 				cmd.log.Debug(err)
 				continue
 			}
-			t := time.Unix(timestamp, 0)
-			now, err = FixedTimezone(t, cmd.config.Timezone)
-		}
-		if len(lines) == 2 || err != nil {
-			now, err = FixedTimezone(time.Now(), cmd.config.Timezone)
-			if err != nil {
-				cmd.log.Debug(err)
-				continue
-			}
+			t = time.Unix(timestamp, 0).UTC()
 		}
 
 		data[lines[0]] = Stat{
 			Value: fval,
-			Time:  now,
+			Time:  t,
 		}
 	}
 
@@ -116,7 +109,8 @@ func (cmd *Command) runCommand() (map[string]Stat, error) {
 func (cmd *Command) FetchMetrics() ([]Metric, error) {
 	var err error
 
-	stats, err := cmd.runCommand()
+	now := ScheduledNow()
+	stats, err := cmd.runCommand(now)
 	metrics := make([]Metric, 0, len(stats))
 
 	for name, s := range stats {
@@ -127,7 +121,7 @@ func (cmd *Command) FetchMetrics() ([]Metric, error) {
 		})
 	}
 
-	return metrics, err
+	return cmd.config.Apply(metrics, now), err
 }
 
 func (cmd *Command) Teardown() {
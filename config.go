@@ -6,11 +6,17 @@ import (
 )
 
 type Config struct {
-	InputType  string `toml:"input_type"`
-	OutputType string `toml:"output_type"`
-	LogFile    string `toml:"log_file"`
-	LogLevel   string `toml:"log_level"`
-	Target     string `toml:"target"`
+	InputType         string   `toml:"input_type"`
+	OutputType        string   `toml:"output_type"`
+	LogFile           string   `toml:"log_file"`
+	LogLevel          string   `toml:"log_level"`
+	Timezone          string   `toml:"timezone"`
+	Target            string   `toml:"target"`
+	Samples           int      `toml:"samples"`
+	SampleInterval    string   `toml:"sample_interval"`
+	SampleAggregation []string `toml:"sample_aggregation"`
+	Splay             string   `toml:"splay"`
+	SplayMode         string   `toml:"splay_mode"`
 }
 
 func LoadFile(filename string) (string, error) {
@@ -46,9 +52,33 @@ func LoadConfig(c Config, filename string) (Config, error) {
 		config.LogLevel = c.LogLevel
 	}
 
+	if c.Timezone != "" {
+		config.Timezone = c.Timezone
+	}
+
 	if c.Target != "" {
 		config.Target = c.Target
 	}
 
+	if c.Samples > 0 {
+		config.Samples = c.Samples
+	}
+
+	if c.SampleInterval != "" {
+		config.SampleInterval = c.SampleInterval
+	}
+
+	if len(c.SampleAggregation) > 0 {
+		config.SampleAggregation = c.SampleAggregation
+	}
+
+	if c.Splay != "" {
+		config.Splay = c.Splay
+	}
+
+	if c.SplayMode != "" {
+		config.SplayMode = c.SplayMode
+	}
+
 	return config, err
 }
@@ -0,0 +1,703 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var containerIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// cgroup v1 reports no memory limit as the page counter maximum, LONG_MAX rounded down to the page size
+const cgroupV1MemoryUnlimited = math.MaxInt64 &^ 0xffff
+
+// containerNetFields are the net/dev counters reported like the networks of the Docker stats.
+var containerNetFields = map[string]string{
+	"rx_bytes": "rx_bytes", "rx_packets": "rx_packets", "rx_errs": "rx_errors", "rx_drop": "rx_dropped",
+	"tx_bytes": "tx_bytes", "tx_packets": "tx_packets", "tx_errs": "tx_errors", "tx_drop": "tx_dropped",
+}
+
+type Container struct {
+	client     *http.Client
+	config     ContainerConfig
+	containers Filter
+	cgroupV2   bool
+	memTotal   float64
+	log        Logger
+}
+
+// ContainerConfig lists the containers from the Docker Engine API (source "docker", the default)
+// or from the cgroup hierarchy below CgroupRoot (source "cgroup").
+// Containers are named by the NameLabel label, their name or the short ID, metrics are named
+// container.<name>.<stat>, e.g. container.web.cpu.percent. CPU percentages are relative to one CPU
+// and computed against the usage of the previous run. Memory limits above the memory of the host
+// are not reported, like unlimited ones.
+// Cgroup containers get their name and labels from the Docker config below DockerRoot, other
+// cgroups are named by their directory, network counters are read from ProcRoot/<pid>/net/dev
+// of a process in the cgroup.
+type ContainerConfig struct {
+	Source        string                   `toml:"source"`
+	DockerSocket  string                   `toml:"docker_socket"`
+	DockerRoot    string                   `toml:"docker_root"`
+	ProcRoot      string                   `toml:"proc_root"`
+	CgroupRoot    string                   `toml:"cgroup_root"`
+	CgroupPattern string                   `toml:"cgroup_pattern"`
+	NameLabel     string                   `toml:"name_label"`
+	Include       string                   `toml:"include"`
+	Exclude       string                   `toml:"exclude"`
+	StatePath     string                   `toml:"state_path"`
+	Timeout       int                      `toml:"timeout"`
+	Metrics       []ContainerMetricsConfig `toml:"metrics"`
+	TimestampConfig
+}
+
+type ContainerMetricsConfig struct {
+	MetricsConfig
+}
+
+type containerInfo struct {
+	id   string
+	name string
+	// cgroup directory per controller (the same directory on cgroup v2), only set for the cgroup source
+	cgroups map[string]string
+}
+
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// dockerConfig is the part of <DockerRoot>/containers/<id>/config.v2.json used for naming.
+type dockerConfig struct {
+	Name   string `json:"Name"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+type dockerStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage float64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		ThrottlingData struct {
+			Periods          float64 `json:"periods"`
+			ThrottledPeriods float64 `json:"throttled_periods"`
+			ThrottledTime    float64 `json:"throttled_time"`
+		} `json:"throttling_data"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage float64            `json:"usage"`
+		Limit float64            `json:"limit"`
+		Stats map[string]float64 `json:"stats"`
+	} `json:"memory_stats"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string  `json:"op"`
+			Value float64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+	Networks map[string]map[string]float64 `json:"networks"`
+}
+
+type containerCPU struct {
+	Usage float64 `json:"usage"`
+	Time  float64 `json:"time"`
+}
+
+func NewContainer(ctConfig ContainerConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var ct *Container
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return ct, err
+	}
+
+	var config ContainerConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return ct, err
+	}
+
+	if ctConfig.Source != "" {
+		config.Source = ctConfig.Source
+	}
+
+	switch config.Source {
+	case "":
+		config.Source = "docker"
+	case "docker", "cgroup":
+	default:
+		return ct, fmt.Errorf("Invalid source: %s", config.Source)
+	}
+
+	if ctConfig.DockerSocket != "" {
+		config.DockerSocket = ctConfig.DockerSocket
+	} else if config.DockerSocket == "" {
+		config.DockerSocket = "/var/run/docker.sock"
+	}
+
+	if config.CgroupRoot == "" {
+		config.CgroupRoot = "/sys/fs/cgroup"
+	}
+
+	if config.DockerRoot == "" {
+		config.DockerRoot = "/var/lib/docker"
+	}
+
+	if config.ProcRoot == "" {
+		config.ProcRoot = "/proc"
+	}
+
+	if ctConfig.StatePath != "" {
+		config.StatePath = ctConfig.StatePath
+	} else if config.StatePath == "" {
+		config.StatePath = DefaultStatePath("container")
+	}
+
+	if ctConfig.Timeout > 0 {
+		config.Timeout = ctConfig.Timeout
+	}
+
+	for i := range config.Metrics {
+		if config.Metrics[i].Prefix == "" {
+			config.Metrics[i].Prefix = "container"
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return ct, err
+	}
+
+	ct = &Container{
+		config: config,
+		log:    log,
+	}
+
+	ct.containers, err = NewFilter(config.Include, config.Exclude)
+	if err != nil {
+		return ct, err
+	}
+
+	if config.Source == "cgroup" {
+		_, err := os.Stat(filepath.Join(config.CgroupRoot, "cgroup.controllers"))
+		ct.cgroupV2 = err == nil
+	}
+
+	if ct.memTotal, err = readMemTotal(filepath.Join(config.ProcRoot, "meminfo")); err != nil {
+		log.Debug(err)
+	}
+
+	socket := config.DockerSocket
+	ct.client = &http.Client{
+		Timeout: time.Duration(config.Timeout) * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	return ct, err
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+
+	return id
+}
+
+// containerName picks the NameLabel label, the name or the short ID of a container.
+func (ct *Container) containerName(id, name string, labels map[string]string) string {
+	if v, ok := labels[ct.config.NameLabel]; ok && ct.config.NameLabel != "" {
+		name = v
+	}
+
+	if name == "" {
+		name = shortID(id)
+	}
+
+	return sanitizeName(strings.TrimPrefix(name, "/"))
+}
+
+func (ct *Container) dockerGet(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://docker%s", path), nil)
+	if err != nil {
+		return err
+	}
+
+	return getJSON(ct.client, req, v)
+}
+
+func (ct *Container) dockerContainers() ([]containerInfo, error) {
+	var list []dockerContainer
+	var containers []containerInfo
+
+	err := ct.dockerGet("/containers/json", &list)
+	if err != nil {
+		return containers, err
+	}
+
+	for _, c := range list {
+		var name string
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+
+		containers = append(containers, containerInfo{
+			id:   c.ID,
+			name: ct.containerName(c.ID, name, c.Labels),
+		})
+	}
+
+	return containers, nil
+}
+
+func (ct *Container) dockerStats(c containerInfo) (map[string]float64, error) {
+	var s dockerStats
+	stats := make(map[string]float64)
+
+	err := ct.dockerGet(fmt.Sprintf("/containers/%s/stats?stream=false&one-shot=true", c.id), &s)
+	if err != nil {
+		return stats, err
+	}
+
+	stats["cpu.usage_seconds"] = s.CPUStats.CPUUsage.TotalUsage / 1e9
+	stats["cpu.periods"] = s.CPUStats.ThrottlingData.Periods
+	stats["cpu.throttled_periods"] = s.CPUStats.ThrottlingData.ThrottledPeriods
+	stats["cpu.throttled_seconds"] = s.CPUStats.ThrottlingData.ThrottledTime / 1e9
+
+	// like "docker stats", page cache is not counted as usage
+	cache, ok := s.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		cache = s.MemoryStats.Stats["total_inactive_file"]
+	}
+	stats["memory.usage"] = s.MemoryStats.Usage - cache
+	stats["memory.limit"] = s.MemoryStats.Limit
+
+	stats["blkio.read_bytes"] = 0
+	stats["blkio.write_bytes"] = 0
+	for _, io := range s.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(io.Op) {
+		case "read":
+			stats["blkio.read_bytes"] += io.Value
+		case "write":
+			stats["blkio.write_bytes"] += io.Value
+		}
+	}
+
+	for _, iface := range s.Networks {
+		for _, f := range []string{"rx_bytes", "rx_packets", "rx_errors", "rx_dropped", "tx_bytes", "tx_packets", "tx_errors", "tx_dropped"} {
+			stats[fmt.Sprintf("net.%s", f)] += iface[f]
+		}
+	}
+
+	return stats, nil
+}
+
+// readMemTotal returns the memory of the host in bytes.
+func readMemTotal(path string) (float64, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, l := range strings.Split(string(buf), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			return parseFloats(fields[1:2])[0] * 1024, nil
+		}
+	}
+
+	return 0, fmt.Errorf("MemTotal not found in %s", path)
+}
+
+// cgroupContainers finds the container cgroups, by default the ones created by Docker with the systemd
+// (system.slice/docker-<id>.scope) or cgroupfs (docker/<id>) driver.
+func (ct *Container) cgroupContainers() ([]containerInfo, error) {
+	var containers []containerInfo
+
+	v2 := ct.cgroupV2
+	base := ct.config.CgroupRoot
+	if !v2 {
+		base = filepath.Join(ct.config.CgroupRoot, "cpuacct")
+	}
+
+	patterns := []string{ct.config.CgroupPattern}
+	if ct.config.CgroupPattern == "" {
+		patterns = []string{"system.slice/docker-*.scope", "docker/*"}
+	}
+
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		dirs, err := filepath.Glob(filepath.Join(base, pattern))
+		if err != nil {
+			return containers, err
+		}
+
+		for _, dir := range dirs {
+			fi, err := os.Stat(dir)
+			if err != nil || !fi.IsDir() {
+				continue
+			}
+
+			rel, err := filepath.Rel(base, dir)
+			if err != nil || seen[rel] {
+				continue
+			}
+			seen[rel] = true
+
+			id := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(dir), "docker-"), ".scope")
+			c := containerInfo{
+				id:      id,
+				name:    ct.cgroupName(id),
+				cgroups: make(map[string]string),
+			}
+
+			for _, controller := range []string{"cpuacct", "cpu", "memory", "blkio"} {
+				if v2 {
+					c.cgroups[controller] = dir
+				} else {
+					c.cgroups[controller] = filepath.Join(ct.config.CgroupRoot, controller, rel)
+				}
+			}
+
+			containers = append(containers, c)
+		}
+	}
+
+	return containers, nil
+}
+
+// cgroupName names a container found in the cgroup hierarchy, Docker containers like the docker source,
+// other cgroups by their directory.
+func (ct *Container) cgroupName(id string) string {
+	if !containerIDPattern.MatchString(id) {
+		return sanitizeName(id)
+	}
+
+	var config dockerConfig
+	buf, err := ioutil.ReadFile(filepath.Join(ct.config.DockerRoot, "containers", id, "config.v2.json"))
+	if err == nil {
+		err = json.Unmarshal(buf, &config)
+	}
+
+	if err != nil {
+		ct.log.Debug(fmt.Sprintf("%s: %s", shortID(id), err))
+	}
+
+	return ct.containerName(id, config.Name, config.Config.Labels)
+}
+
+// cgroupNetStats sums the network counters of the network namespace of the first process in dir,
+// the loopback interface is left out.
+func (ct *Container) cgroupNetStats(dir string, stats map[string]float64) error {
+	procs, err := readCgroupFile(dir, "cgroup.procs")
+	if err != nil {
+		return err
+	}
+
+	pids := strings.Fields(procs)
+	if len(pids) == 0 {
+		return nil
+	}
+
+	ifaces, err := readNetDev(filepath.Join(ct.config.ProcRoot, pids[0], "net", "dev"))
+	if err != nil {
+		return err
+	}
+
+	for i, f := range netFields {
+		if name, ok := containerNetFields[f]; ok {
+			stats[fmt.Sprintf("net.%s", name)] = 0
+			for iface, values := range ifaces {
+				if iface != "lo" {
+					stats[fmt.Sprintf("net.%s", name)] += values[i]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func readCgroupFile(dir, name string) (string, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+	return strings.TrimSpace(string(buf)), err
+}
+
+// readKeyValues reads flat keyed files like cpu.stat and memory.stat.
+func readKeyValues(dir, name string) (map[string]float64, error) {
+	values := make(map[string]float64)
+
+	s, err := readCgroupFile(dir, name)
+	if err != nil {
+		return values, err
+	}
+
+	for _, l := range strings.Split(s, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) != 2 {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err == nil {
+			values[fields[0]] = v
+		}
+	}
+
+	return values, nil
+}
+
+func (ct *Container) cgroupStats(c containerInfo) (map[string]float64, error) {
+	var err error
+	var stats map[string]float64
+	if ct.cgroupV2 {
+		stats, err = cgroupV2Stats(c.cgroups["cpu"])
+	} else {
+		stats, err = cgroupV1Stats(c.cgroups)
+	}
+
+	if err != nil {
+		return stats, err
+	}
+
+	// the process may have exited in the meantime
+	if err := ct.cgroupNetStats(c.cgroups["cpu"], stats); err != nil {
+		ct.log.Debug(fmt.Sprintf("%s: %s", c.name, err))
+	}
+
+	return stats, nil
+}
+
+func cgroupV2Stats(dir string) (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	cpu, err := readKeyValues(dir, "cpu.stat")
+	if err != nil {
+		return stats, err
+	}
+
+	stats["cpu.usage_seconds"] = cpu["usage_usec"] / 1e6
+	stats["cpu.periods"] = cpu["nr_periods"]
+	stats["cpu.throttled_periods"] = cpu["nr_throttled"]
+	stats["cpu.throttled_seconds"] = cpu["throttled_usec"] / 1e6
+
+	usage, err := readCgroupFile(dir, "memory.current")
+	if err != nil {
+		return stats, err
+	}
+
+	mem, err := readKeyValues(dir, "memory.stat")
+	if err != nil {
+		return stats, err
+	}
+	stats["memory.usage"] = parseFloats([]string{usage})[0] - mem["inactive_file"]
+
+	// "max" means unlimited
+	if limit, err := readCgroupFile(dir, "memory.max"); err == nil && limit != "max" {
+		stats["memory.limit"] = parseFloats([]string{limit})[0]
+	}
+
+	// io.stat: "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0"
+	stats["blkio.read_bytes"] = 0
+	stats["blkio.write_bytes"] = 0
+	if s, err := readCgroupFile(dir, "io.stat"); err == nil {
+		for _, l := range strings.Split(s, "\n") {
+			for _, f := range strings.Fields(l) {
+				kv := strings.SplitN(f, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+
+				switch kv[0] {
+				case "rbytes":
+					stats["blkio.read_bytes"] += parseFloats(kv[1:])[0]
+				case "wbytes":
+					stats["blkio.write_bytes"] += parseFloats(kv[1:])[0]
+				}
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+func cgroupV1Stats(cgroups map[string]string) (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	usage, err := readCgroupFile(cgroups["cpuacct"], "cpuacct.usage")
+	if err != nil {
+		return stats, err
+	}
+	stats["cpu.usage_seconds"] = parseFloats([]string{usage})[0] / 1e9
+
+	if cpu, err := readKeyValues(cgroups["cpu"], "cpu.stat"); err == nil {
+		stats["cpu.periods"] = cpu["nr_periods"]
+		stats["cpu.throttled_periods"] = cpu["nr_throttled"]
+		stats["cpu.throttled_seconds"] = cpu["throttled_time"] / 1e9
+	}
+
+	usage, err = readCgroupFile(cgroups["memory"], "memory.usage_in_bytes")
+	if err != nil {
+		return stats, err
+	}
+
+	mem, err := readKeyValues(cgroups["memory"], "memory.stat")
+	if err != nil {
+		return stats, err
+	}
+	stats["memory.usage"] = parseFloats([]string{usage})[0] - mem["total_inactive_file"]
+
+	if limit, err := readCgroupFile(cgroups["memory"], "memory.limit_in_bytes"); err == nil {
+		if l := parseFloats([]string{limit})[0]; l < cgroupV1MemoryUnlimited {
+			stats["memory.limit"] = l
+		}
+	}
+
+	// blkio.throttle.io_service_bytes: "8:0 Read 1024"
+	stats["blkio.read_bytes"] = 0
+	stats["blkio.write_bytes"] = 0
+	if s, err := readCgroupFile(cgroups["blkio"], "blkio.throttle.io_service_bytes"); err == nil {
+		for _, l := range strings.Split(s, "\n") {
+			fields := strings.Fields(l)
+			if len(fields) != 3 {
+				continue
+			}
+
+			switch fields[1] {
+			case "Read":
+				stats["blkio.read_bytes"] += parseFloats(fields[2:])[0]
+			case "Write":
+				stats["blkio.write_bytes"] += parseFloats(fields[2:])[0]
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+func (ct *Container) FetchMetrics() ([]Metric, error) {
+	var err error
+	var containers []containerInfo
+
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	if ct.config.Source == "cgroup" {
+		containers, err = ct.cgroupContainers()
+	} else {
+		containers, err = ct.dockerContainers()
+	}
+
+	metrics = append(metrics, SectionMetrics("container", err, now)...)
+	if err != nil {
+		return ct.config.Apply(metrics, now), err
+	}
+
+	state, err := NewState(ct.config.StatePath)
+	if err != nil {
+		return ct.config.Apply(metrics, now), err
+	}
+	defer state.Close()
+
+	stats := make(map[string]float64)
+	ids := make(map[string]bool)
+	var errs MultiError
+	for _, c := range containers {
+		ids[c.id] = true
+		if !ct.containers.Match(c.name) {
+			continue
+		}
+
+		var s map[string]float64
+		if ct.config.Source == "cgroup" {
+			s, err = ct.cgroupStats(c)
+		} else {
+			s, err = ct.dockerStats(c)
+		}
+
+		if err != nil {
+			// the container has stopped in the meantime
+			if os.IsNotExist(err) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", c.name, err))
+			continue
+		}
+
+		if limit, ok := s["memory.limit"]; ok && ct.memTotal > 0 && limit > ct.memTotal {
+			delete(s, "memory.limit")
+		}
+
+		if limit := s["memory.limit"]; limit > 0 {
+			s["memory.percent"] = s["memory.usage"] / limit * 100
+		}
+
+		// the rate base is the wall clock, now is the scheduled time of the run
+		var prev containerCPU
+		cur := containerCPU{
+			Usage: s["cpu.usage_seconds"],
+			Time:  float64(time.Now().UnixNano()) / float64(time.Second),
+		}
+
+		found, err := state.Get("container", c.id, &prev)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if found && cur.Time > prev.Time && cur.Usage >= prev.Usage {
+			s["cpu.percent"] = (cur.Usage - prev.Usage) / (cur.Time - prev.Time) * 100
+		}
+
+		if err = state.Put("container", c.id, cur); err != nil {
+			errs = append(errs, err)
+		}
+
+		for k, v := range s {
+			stats[fmt.Sprintf("%s.%s", c.name, k)] = v
+		}
+	}
+
+	// removed containers would otherwise stay in the state forever
+	if err = state.Prune("container", ids); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(ct.config.Metrics) == 0 {
+		for _, m := range AllMetrics(stats, now) {
+			m.Name = fmt.Sprintf("container.%s", m.Name)
+			metrics = append(metrics, m)
+		}
+	}
+
+	for _, m := range ct.config.Metrics {
+		metrics = append(metrics, m.Metrics(stats, now)...)
+	}
+
+	return ct.config.Apply(metrics, now), errs.ErrorOrNil()
+}
+
+func (ct *Container) Teardown() {
+
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cgroupV1Fixture writes the cgroup v1 files of a Docker container (cgroupfs driver) below root.
+func cgroupV1Fixture(t *testing.T, root, id, limit string, pid int) {
+	files := map[string]string{
+		"cpuacct/docker/%s/cpuacct.usage":                 "2000000000\n",
+		"cpuacct/docker/%s/cgroup.procs":                  fmt.Sprintf("%d\n", pid),
+		"cpu/docker/%s/cpu.stat":                          "nr_periods 10\nnr_throttled 1\nthrottled_time 500000000\n",
+		"cpu/docker/%s/cgroup.procs":                      fmt.Sprintf("%d\n", pid),
+		"memory/docker/%s/memory.usage_in_bytes":          "104857600\n",
+		"memory/docker/%s/memory.stat":                    "cache 20971520\ntotal_inactive_file 20971520\n",
+		"memory/docker/%s/memory.limit_in_bytes":          limit + "\n",
+		"blkio/docker/%s/blkio.throttle.io_service_bytes": "8:0 Read 1024\n8:0 Write 2048\n8:0 Total 3072\nTotal 3072\n",
+	}
+
+	for name, content := range files {
+		writeProcFixture(t, root, map[string]string{fmt.Sprintf(name, id): content})
+	}
+}
+
+func TestContainerFetchMetricsCgroupV1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	web := strings.Repeat("a", 64)
+	batch := strings.Repeat("b", 64)
+
+	cgroupRoot := filepath.Join(dir, "cgroup")
+	cgroupV1Fixture(t, cgroupRoot, web, "268435456", 100)
+	cgroupV1Fixture(t, cgroupRoot, batch, "9223372036854771712", 200)
+
+	writeProcFixture(t, filepath.Join(dir, "proc"), map[string]string{
+		"meminfo": "MemTotal:        4000000 kB\nMemFree:          500000 kB\n",
+		"100/net/dev": `Inter-|   Receive |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  100 1 0 0 0 0 0 0 100 1 0 0 0 0 0 0
+  eth0: 2000 20 0 0 0 0 0 0 3000 30 0 0 0 0 0 0
+`,
+	})
+
+	writeProcFixture(t, filepath.Join(dir, "docker"), map[string]string{
+		fmt.Sprintf("containers/%s/config.v2.json", web): `{"Name":"/web","Config":{"Labels":{}}}`,
+	})
+
+	conf := filepath.Join(dir, "container.toml")
+	err = ioutil.WriteFile(conf, []byte(fmt.Sprintf("source = \"cgroup\"\ncgroup_root = %q\nproc_root = %q\ndocker_root = %q\nstate_path = %q\n",
+		cgroupRoot, filepath.Join(dir, "proc"), filepath.Join(dir, "docker"), filepath.Join(dir, "state.db"))), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewContainer(ContainerConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func() map[string]float64 {
+		metrics, err := in.FetchMetrics()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := make(map[string]float64)
+		for _, m := range metrics {
+			stats[m.Name] = m.Value.(float64)
+		}
+
+		return stats
+	}
+
+	stats := fetch()
+	want := map[string]float64{
+		"container.up":                        1,
+		"container.web.cpu.usage_seconds":     2,
+		"container.web.cpu.throttled_seconds": 0.5,
+		"container.web.memory.usage":          80 * 1024 * 1024,
+		"container.web.memory.limit":          256 * 1024 * 1024,
+		"container.web.memory.percent":        31.25,
+		"container.web.blkio.read_bytes":      1024,
+		"container.web.net.rx_bytes":          2000,
+		"container.web.net.tx_packets":        30,
+		"container.bbbbbbbbbbbb.memory.usage": 80 * 1024 * 1024,
+	}
+
+	for name, v := range want {
+		if got, ok := stats[name]; !ok || got != v {
+			t.Errorf("%s = %v (found %v), want %v", name, got, ok, v)
+		}
+	}
+
+	// an unlimited container has no limit
+	for _, name := range []string{"container.bbbbbbbbbbbb.memory.limit", "container.bbbbbbbbbbbb.memory.percent"} {
+		if _, ok := stats[name]; ok {
+			t.Errorf("unexpected %s", name)
+		}
+	}
+
+	// the state of removed containers is pruned
+	if err := os.RemoveAll(filepath.Join(cgroupRoot, "cpuacct", "docker", batch)); err != nil {
+		t.Fatal(err)
+	}
+	fetch()
+
+	state, err := NewState(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	for id, want := range map[string]bool{web: true, batch: false} {
+		var cpu containerCPU
+		found, err := state.Get("container", id, &cpu)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found != want {
+			t.Errorf("state of %s found: %v, want %v", shortID(id), found, want)
+		}
+	}
+}
+
+func TestContainerMemoryLimitAboveHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	id := strings.Repeat("c", 64)
+	cgroupRoot := filepath.Join(dir, "cgroup")
+	// 16 GiB on a 4 GB host
+	cgroupV1Fixture(t, cgroupRoot, id, "17179869184", 300)
+	writeProcFixture(t, filepath.Join(dir, "proc"), map[string]string{
+		"meminfo": "MemTotal:        4000000 kB\n",
+	})
+
+	conf := filepath.Join(dir, "container.toml")
+	err = ioutil.WriteFile(conf, []byte(fmt.Sprintf("source = \"cgroup\"\ncgroup_root = %q\nproc_root = %q\nstate_path = %q\n",
+		cgroupRoot, filepath.Join(dir, "proc"), filepath.Join(dir, "state.db"))), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewContainer(ContainerConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := in.FetchMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range metrics {
+		if strings.HasSuffix(m.Name, ".memory.limit") || strings.HasSuffix(m.Name, ".memory.percent") {
+			t.Errorf("unexpected %s = %v", m.Name, m.Value)
+		}
+	}
+}
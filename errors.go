@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+)
+
+type MultiError []error
+
+func (me MultiError) Error() string {
+	msgs := make([]string, 0, len(me))
+	for _, err := range me {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// ErrorOrNil returns nil when no error has been collected,
+// so that a MultiError can be returned as a plain error.
+func (me MultiError) ErrorOrNil() error {
+	if len(me) == 0 {
+		return nil
+	}
+
+	return me
+}
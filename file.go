@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type File struct {
+	config FileConfig
+	log    Logger
+}
+
+type FileConfig struct {
+	Files []FileMetricsConfig `toml:"files"`
+	TimestampConfig
+}
+
+// FileMetricsConfig matches regular files by the glob patterns in Name ("**" matches any number of directories),
+// e.g. "/backup/mysql-*.sql.gz". Metrics are named <prefix>.<alias>.<stat>, the prefix defaults to "file"
+// and the alias to the sanitized Name. CountLines counts the lines of all matched files.
+// Unit divides the sizes, e.g. 1048576 reports them in MiB, counts and ages are not affected.
+type FileMetricsConfig struct {
+	CountLines bool `toml:"count_lines"`
+	MetricsConfig
+}
+
+func NewFile(fileConfig FileConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var f *File
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return f, err
+	}
+
+	var config FileConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return f, err
+	}
+
+	for i := range config.Files {
+		fc := &config.Files[i]
+
+		if fc.Name == "" {
+			return f, fmt.Errorf("files[%d]: name is required", i)
+		}
+
+		if fc.Alias == "" {
+			fc.Alias = sanitizeName(fc.Name)
+		}
+
+		if fc.Prefix == "" {
+			fc.Prefix = "file"
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return f, err
+	}
+
+	f = &File{
+		config: config,
+		log:    log,
+	}
+
+	return f, err
+}
+
+// Glob is filepath.Glob with support for "**", which matches any number of directories.
+func Glob(pattern string) ([]string, error) {
+	i := strings.Index(pattern, "**")
+	if i < 0 {
+		return filepath.Glob(pattern)
+	}
+
+	rest := strings.TrimLeft(pattern[i+2:], string(filepath.Separator))
+	if rest == "" {
+		rest = "*"
+	}
+
+	bases, err := filepath.Glob(filepath.Clean(pattern[:i]))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var matches []string
+	for _, base := range bases {
+		err = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+			// skip unreadable directories
+			if err != nil || path == base {
+				return nil
+			}
+
+			rel, err := filepath.Rel(base, path)
+			if err != nil {
+				return nil
+			}
+
+			// the rest of the pattern has to match the path below any number of directories
+			parts := strings.Split(rel, string(filepath.Separator))
+			for j := range parts {
+				if ok, _ := filepath.Match(rest, filepath.Join(parts[j:]...)); ok && !seen[path] {
+					seen[path] = true
+					matches = append(matches, path)
+					break
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return matches, err
+		}
+	}
+	sort.Strings(matches)
+
+	return matches, nil
+}
+
+func countLines(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var lines float64
+	var size int
+	var last byte
+	buf := make([]byte, 32*1024)
+	r := bufio.NewReader(f)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines += float64(bytes.Count(buf[:n], []byte{'\n'}))
+			last = buf[n-1]
+			size += n
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return lines, err
+		}
+	}
+
+	// the last line may not end with a newline
+	if size > 0 && last != '\n' {
+		lines++
+	}
+
+	return lines, nil
+}
+
+func (f *File) stats(fc FileMetricsConfig, now float64) (map[string]float64, error) {
+	var errs MultiError
+	stats := make(map[string]float64)
+	stats["count"] = 0
+
+	seen := make(map[string]bool)
+	var newest, oldest os.FileInfo
+	for _, pattern := range fc.SplitName() {
+		paths, err := Glob(pattern)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			fi, err := os.Stat(path)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+
+			stats["count"]++
+			stats["total_size"] += float64(fi.Size())
+
+			if newest == nil || fi.ModTime().After(newest.ModTime()) {
+				newest = fi
+			}
+
+			if oldest == nil || fi.ModTime().Before(oldest.ModTime()) {
+				oldest = fi
+			}
+
+			if fc.CountLines {
+				lines, err := countLines(path)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				stats["lines"] += lines
+			}
+		}
+	}
+
+	if newest != nil {
+		stats["newest_age"] = now - float64(newest.ModTime().Unix())
+		stats["newest_size"] = fc.CalcValue(float64(newest.Size()))
+		stats["oldest_age"] = now - float64(oldest.ModTime().Unix())
+	}
+
+	if _, ok := stats["total_size"]; ok {
+		stats["total_size"] = fc.CalcValue(stats["total_size"])
+	}
+
+	return stats, errs.ErrorOrNil()
+}
+
+func (f *File) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	// ages use the wall clock, now is the scheduled time of the run
+	wall := float64(time.Now().Unix())
+
+	var errs MultiError
+	for _, fc := range f.config.Files {
+		stats, err := f.stats(fc, wall)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", fc.Name, err))
+		}
+
+		for _, key := range sortedKeys(stats) {
+			metrics = append(metrics, Metric{
+				Name:  fmt.Sprintf("%s.%s.%s", fc.Prefix, fc.Alias, key),
+				Value: stats[key],
+				Time:  now,
+			})
+		}
+	}
+
+	return f.config.Apply(metrics, now), errs.ErrorOrNil()
+}
+
+func (f *File) Teardown() {
+
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileFetchMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wall := time.Now()
+	files := []struct {
+		name    string
+		content string
+		age     time.Duration
+	}{
+		{"backup/db-1.sql", "a\nb\n", 100 * time.Second},
+		{"backup/db-2.sql", "c\nd\ne", 10 * time.Second},
+		{"backup/old/db-0.sql", "f\n", 1000 * time.Second},
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(f.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		mtime := wall.Add(-f.age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		want   map[string]float64
+	}{
+		{
+			name:   "glob",
+			config: "name = %q\nalias = \"backup\"\ncount_lines = true\n",
+			want: map[string]float64{
+				"file.backup.count":       2,
+				"file.backup.total_size":  9,
+				"file.backup.newest_size": 5,
+				"file.backup.lines":       5,
+				"file.backup.newest_age":  10,
+				"file.backup.oldest_age":  100,
+			},
+		},
+		{
+			name:   "recursive glob with a unit",
+			config: "name = %q\nalias = \"backup\"\nunit = 2.0\n",
+			want: map[string]float64{
+				"file.backup.count":       3,
+				"file.backup.total_size":  5.5,
+				"file.backup.newest_size": 2.5,
+				"file.backup.newest_age":  10,
+				"file.backup.oldest_age":  1000,
+			},
+		},
+	}
+
+	patterns := []string{filepath.Join(dir, "backup", "db-*.sql"), filepath.Join(dir, "backup", "**", "db-*.sql")}
+
+	// the run was scheduled before the files were written, ages must not depend on it
+	ScheduleRun(wall.Add(-time.Hour))
+	defer ScheduleRun(time.Time{})
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := filepath.Join(dir, fmt.Sprintf("file%d.toml", i))
+			err := ioutil.WriteFile(conf, []byte("[[files]]\n"+fmt.Sprintf(tt.config, patterns[i])), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in, err := NewFile(FileConfig{}, conf, NewLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metrics, err := in.FetchMetrics()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stats := make(map[string]float64)
+			for _, m := range metrics {
+				stats[m.Name] = m.Value.(float64)
+			}
+
+			for name, want := range tt.want {
+				// ages are in whole seconds of the wall clock
+				var slack float64
+				if strings.HasSuffix(name, "_age") {
+					slack = 2
+				}
+
+				got, ok := stats[name]
+				if !ok || got < want-slack || got > want+slack {
+					t.Errorf("%s = %v (found %v), want %v", name, got, ok, want)
+				}
+			}
+		})
+	}
+}
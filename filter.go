@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+)
+
+// Filter selects names by include and exclude regular expressions.
+// An empty include matches everything.
+type Filter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func NewFilter(include, exclude string) (Filter, error) {
+	var err error
+	f := Filter{}
+
+	if include != "" {
+		f.include, err = regexp.Compile(include)
+		if err != nil {
+			return f, err
+		}
+	}
+
+	if exclude != "" {
+		f.exclude, err = regexp.Compile(exclude)
+	}
+
+	return f, err
+}
+
+func (f Filter) Match(name string) bool {
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+
+	return true
+}
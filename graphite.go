@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Graphite receives the Graphite plaintext protocol (<name> <value> [<timestamp>]) and relays
+// the received points every flush interval.
+type Graphite struct {
+	config   GraphiteConfig
+	interval time.Duration
+	nameTmpl *template.Template
+	server   *LineServer
+	metrics  []Metric
+	mu       sync.Mutex
+	done     chan struct{}
+	once     sync.Once
+	log      Logger
+}
+
+// GraphiteConfig listens on TCPAddress (":2003" by default) and UDPAddress (disabled by default).
+// Tagged names (name;tag=value) are flattened with NameTemplate, every point is relayed unless Metrics
+// selects some of them.
+type GraphiteConfig struct {
+	TCPAddress    string                  `toml:"tcp_address"`
+	UDPAddress    string                  `toml:"udp_address"`
+	FlushInterval string                  `toml:"flush_interval"`
+	NameTemplate  string                  `toml:"name_template"`
+	Metrics       []GraphiteMetricsConfig `toml:"metrics"`
+	TimestampConfig
+}
+
+type GraphiteMetricsConfig struct {
+	MetricsConfig
+}
+
+func NewGraphite(gConfig GraphiteConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var g *Graphite
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return g, err
+	}
+
+	var config GraphiteConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return g, err
+	}
+
+	if gConfig.TCPAddress != "" {
+		config.TCPAddress = gConfig.TCPAddress
+	} else if config.TCPAddress == "" && config.UDPAddress == "" {
+		config.TCPAddress = ":2003"
+	}
+
+	if gConfig.UDPAddress != "" {
+		config.UDPAddress = gConfig.UDPAddress
+	}
+
+	if gConfig.FlushInterval != "" {
+		config.FlushInterval = gConfig.FlushInterval
+	} else if config.FlushInterval == "" {
+		config.FlushInterval = "60s"
+	}
+
+	var interval time.Duration
+	interval, err = time.ParseDuration(config.FlushInterval)
+	if err != nil {
+		return g, err
+	}
+
+	if interval <= 0 {
+		return g, fmt.Errorf("Invalid flush_interval: %s", config.FlushInterval)
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return g, err
+	}
+
+	var tmpl *template.Template
+	tmpl, err = NewNameTemplate(config.NameTemplate)
+	if err != nil {
+		return g, err
+	}
+
+	g = &Graphite{
+		config:   config,
+		interval: interval,
+		nameTmpl: tmpl,
+		metrics:  make([]Metric, 0),
+		done:     make(chan struct{}),
+		log:      log,
+	}
+
+	return g, err
+}
+
+// parseGraphiteName splits a tagged name (e.g. "disk.used;host=db1;mount=/") into the name and its tags.
+func parseGraphiteName(s string) (string, map[string]string) {
+	parts := strings.Split(s, ";")
+
+	var labels map[string]string
+	for _, tag := range parts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[kv[0]] = kv[1]
+	}
+
+	return parts[0], labels
+}
+
+// selectMetric returns the metric of a received point, or false if no metrics config selects it.
+func (g *Graphite) selectMetric(name string, value float64, t time.Time) (Metric, bool) {
+	if len(g.config.Metrics) == 0 {
+		return Metric{Name: name, Value: value, Time: t}, true
+	}
+
+	for _, m := range g.config.Metrics {
+		if n, ok := m.Select(name); ok {
+			return Metric{Name: n, Value: m.CalcValue(value), Time: t}, true
+		}
+	}
+
+	return Metric{}, false
+}
+
+// Handle parses a line formatted as <name> <value> [<timestamp>], a missing or negative timestamp
+// is replaced by the time the line was received.
+func (g *Graphite) Handle(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return fmt.Errorf("Invalid line: %s", line)
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("Invalid value: %s", line)
+	}
+
+	now := time.Now().UTC()
+	if len(fields) == 3 {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("Invalid timestamp: %s", line)
+		}
+
+		if ts >= 0 {
+			now = time.Unix(int64(ts), 0).UTC()
+		}
+	}
+
+	name, labels := parseGraphiteName(fields[0])
+	name, err = LabelName(g.nameTmpl, name, labels, "")
+	if err != nil {
+		return err
+	}
+
+	m, ok := g.selectMetric(name, value, now)
+	if !ok {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.metrics = append(g.metrics, m)
+
+	return nil
+}
+
+func (g *Graphite) handle(line string) {
+	if err := g.Handle(line); err != nil {
+		g.log.Debug(err)
+	}
+}
+
+// FetchMetrics returns the points received since the previous flush.
+func (g *Graphite) FetchMetrics() ([]Metric, error) {
+	g.mu.Lock()
+	metrics := g.metrics
+	g.metrics = make([]Metric, 0)
+	g.mu.Unlock()
+
+	return g.config.Apply(metrics, ScheduledNow()), nil
+}
+
+// Listen receives points until Teardown is called, the pending points are flushed on shutdown.
+func (g *Graphite) Listen(flush func([]Metric)) error {
+	var err error
+	g.server, err = NewLineServer(g.config.UDPAddress, g.config.TCPAddress, g.handle, g.log)
+	if err != nil {
+		return err
+	}
+
+	FlushEvery(g, g.interval, g.done, g.server.Close, flush)
+
+	return nil
+}
+
+func (g *Graphite) Teardown() {
+	g.once.Do(func() {
+		close(g.done)
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestGraphite(t *testing.T, config string) *Graphite {
+	dir, err := ioutil.TempDir("", "graphite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := filepath.Join(dir, "graphite.toml")
+	if err := ioutil.WriteFile(conf, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewGraphite(GraphiteConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return in.(*Graphite)
+}
+
+func TestGraphiteHandle(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		line   string
+		// the zero time is the time the line was received
+		want    []Metric
+		wantErr bool
+	}{
+		{
+			name: "with timestamp",
+			line: "servers.web1.load 1.5 1500000000",
+			want: []Metric{{Name: "servers.web1.load", Value: 1.5, Time: time.Unix(1500000000, 0).UTC()}},
+		},
+		{
+			name: "without timestamp",
+			line: "servers.web1.load 2",
+			want: []Metric{{Name: "servers.web1.load", Value: 2.0}},
+		},
+		{
+			name: "negative timestamp",
+			line: "servers.web1.load 2 -1",
+			want: []Metric{{Name: "servers.web1.load", Value: 2.0}},
+		},
+		{
+			name: "tagged name",
+			line: "disk.used;mount=/var;host=db1 75 1500000000",
+			want: []Metric{{Name: "disk.used.db1.var", Value: 75.0, Time: time.Unix(1500000000, 0).UTC()}},
+		},
+		{
+			name:   "selected by metrics",
+			config: "[[metrics]]\nname = \"servers.*.load\"\nprefix = \"graphite\"\nunit = 2.0\n",
+			line:   "servers.web1.load 3 1500000000",
+			want:   []Metric{{Name: "graphite.servers.web1.load", Value: 1.5, Time: time.Unix(1500000000, 0).UTC()}},
+		},
+		{
+			name:   "not selected by metrics",
+			config: "[[metrics]]\nname = \"servers.*.load\"\n",
+			line:   "servers.web1.memory 3",
+		},
+		{
+			name:    "missing value",
+			line:    "servers.web1.load",
+			wantErr: true,
+		},
+		{
+			name:    "too many fields",
+			line:    "servers.web1.load 1 1500000000 extra",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			line:    "servers.web1.load NaN",
+			wantErr: true,
+		},
+		{
+			name:    "invalid timestamp",
+			line:    "servers.web1.load 1 yesterday",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGraphite(t, tt.config)
+
+			before := time.Now()
+			err := g.Handle(tt.line)
+			after := time.Now()
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metrics, _ := g.FetchMetrics()
+			if len(metrics) != len(tt.want) {
+				t.Fatalf("metrics = %v, want %v", metrics, tt.want)
+			}
+
+			for i, want := range tt.want {
+				got := metrics[i]
+				if got.Name != want.Name || got.Value != want.Value {
+					t.Errorf("got %s = %v, want %s = %v", got.Name, got.Value, want.Name, want.Value)
+				}
+
+				if want.Time.IsZero() {
+					if got.Time.Before(before.Truncate(time.Second)) || got.Time.After(after) {
+						t.Errorf("time = %v, want the time the line was received", got.Time)
+					}
+				} else if !got.Time.Equal(want.Time) {
+					t.Errorf("time = %v, want %v", got.Time, want.Time)
+				}
+			}
+		})
+	}
+}
+
+func TestFlushEvery(t *testing.T) {
+	g := newTestGraphite(t, "")
+
+	flushed := make(chan []Metric)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		FlushEvery(g, 10*time.Millisecond, done, func() {
+			// everything received until the server is stopped is in the last flush
+			g.Handle("servers.web1.load 2 1500000060")
+		}, func(metrics []Metric) {
+			flushed <- metrics
+		})
+		close(stopped)
+	}()
+
+	g.Handle("servers.web1.load 1 1500000000")
+
+	metrics := <-flushed
+	if len(metrics) != 1 || metrics[0].Value != 1.0 {
+		t.Fatalf("first flush = %v, want servers.web1.load = 1", metrics)
+	}
+
+	// empty intervals are not flushed
+	time.Sleep(30 * time.Millisecond)
+
+	close(done)
+
+	metrics = <-flushed
+	if len(metrics) != 1 || metrics[0].Value != 2.0 {
+		t.Fatalf("last flush = %v, want servers.web1.load = 2", metrics)
+	}
+
+	select {
+	case <-stopped:
+	case metrics = <-flushed:
+		t.Fatalf("unexpected flush after shutdown: %v", metrics)
+	case <-time.After(time.Second):
+		t.Fatal("FlushEvery did not return")
+	}
+}
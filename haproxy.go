@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultHAProxyFields = []string{"qcur", "qmax", "scur", "smax", "slim", "stot", "bin", "bout",
+	"ereq", "econ", "eresp", "status", "chkfail", "downtime", "rate", "req_rate",
+	"hrsp_1xx", "hrsp_2xx", "hrsp_3xx", "hrsp_4xx", "hrsp_5xx", "qtime", "ctime", "rtime", "ttime"}
+
+type HAProxy struct {
+	client  *http.Client
+	config  HAProxyConfig
+	proxies Filter
+	servers Filter
+	log     Logger
+}
+
+// HAProxyConfig reads "show stat" from the admin Socket or the CSV stats page at URL.
+// Metrics are named haproxy.<pxname>.<svname>.<field>, svname is FRONTEND or BACKEND for the proxies themselves.
+// The status field is reported as 1 (UP, OPEN, no check) or 0. Metrics select stats without the
+// "haproxy." prefix (e.g. "web.*.scur"), every stat is reported if none are given.
+type HAProxyConfig struct {
+	Socket        string                 `toml:"socket"`
+	URL           string                 `toml:"url"`
+	User          string                 `toml:"user"`
+	Password      string                 `toml:"password"`
+	Timeout       int                    `toml:"timeout"`
+	ProxyInclude  string                 `toml:"proxy_include"`
+	ProxyExclude  string                 `toml:"proxy_exclude"`
+	ServerInclude string                 `toml:"server_include"`
+	ServerExclude string                 `toml:"server_exclude"`
+	Fields        []string               `toml:"fields"`
+	Metrics       []HAProxyMetricsConfig `toml:"metrics"`
+	TLSConfig
+	TimestampConfig
+}
+
+type HAProxyMetricsConfig struct {
+	MetricsConfig
+}
+
+func NewHAProxy(hapConfig HAProxyConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var hap *HAProxy
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return hap, err
+	}
+
+	var config HAProxyConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return hap, err
+	}
+
+	if hapConfig.Socket != "" {
+		config.Socket = hapConfig.Socket
+	}
+
+	if hapConfig.URL != "" {
+		config.URL = hapConfig.URL
+	}
+
+	if hapConfig.User != "" {
+		config.User = hapConfig.User
+	}
+
+	if hapConfig.Password != "" {
+		config.Password = hapConfig.Password
+	}
+
+	if hapConfig.Timeout > 0 {
+		config.Timeout = hapConfig.Timeout
+	} else if config.Timeout == 0 {
+		config.Timeout = 10
+	}
+
+	if config.Socket == "" && config.URL == "" {
+		return hap, errors.New("socket or url is required")
+	}
+
+	if config.URL != "" && !strings.HasSuffix(config.URL, ";csv") {
+		config.URL = fmt.Sprintf("%s;csv", strings.TrimSuffix(config.URL, "/"))
+	}
+
+	if len(config.Fields) == 0 {
+		config.Fields = defaultHAProxyFields
+	}
+
+	for i := range config.Metrics {
+		if config.Metrics[i].Prefix == "" {
+			config.Metrics[i].Prefix = "haproxy"
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return hap, err
+	}
+
+	hap = &HAProxy{
+		config: config,
+		log:    log,
+	}
+
+	hap.proxies, err = NewFilter(config.ProxyInclude, config.ProxyExclude)
+	if err != nil {
+		return hap, err
+	}
+
+	hap.servers, err = NewFilter(config.ServerInclude, config.ServerExclude)
+	if err != nil {
+		return hap, err
+	}
+
+	hap.client, err = NewHTTPClient(config.TLSConfig, config.Timeout)
+
+	return hap, err
+}
+
+func (hap *HAProxy) fetchSocket() ([]byte, error) {
+	timeout := time.Duration(hap.config.Timeout) * time.Second
+
+	conn, err := net.DialTimeout("unix", hap.config.Socket, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	_, err = io.WriteString(conn, "show stat\n")
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(conn)
+}
+
+func (hap *HAProxy) fetchURL() ([]byte, error) {
+	req, err := http.NewRequest("GET", hap.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if hap.config.User != "" {
+		req.SetBasicAuth(hap.config.User, hap.config.Password)
+	}
+
+	resp, err := hap.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s: %s", hap.config.URL, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func haproxyStatus(status string) float64 {
+	for _, up := range []string{"UP", "OPEN", "no check"} {
+		if strings.HasPrefix(status, up) {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// ParseHAProxyStat parses the CSV output of "show stat", the first line is the "# pxname,svname,..." header.
+func ParseHAProxyStat(r io.Reader) ([]map[string]string, error) {
+	var rows []map[string]string
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return rows, err
+	}
+
+	if len(header) == 0 || !strings.HasPrefix(header[0], "# ") {
+		return rows, fmt.Errorf("Invalid header: %s", strings.Join(header, ","))
+	}
+	header[0] = strings.TrimPrefix(header[0], "# ")
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return rows, err
+		}
+
+		row := make(map[string]string)
+		for i, v := range record {
+			if i < len(header) && header[i] != "" {
+				row[header[i]] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func (hap *HAProxy) stats() (map[string]float64, error) {
+	var err error
+	var buf []byte
+	stats := make(map[string]float64)
+
+	if hap.config.Socket != "" {
+		buf, err = hap.fetchSocket()
+	} else {
+		buf, err = hap.fetchURL()
+	}
+
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err := ParseHAProxyStat(bytes.NewReader(buf))
+	if err != nil {
+		return stats, err
+	}
+
+	for _, row := range rows {
+		px, sv := row["pxname"], row["svname"]
+		if !hap.proxies.Match(px) || !hap.servers.Match(sv) {
+			continue
+		}
+
+		for _, f := range hap.config.Fields {
+			v, ok := row[f]
+			if !ok || v == "" {
+				continue
+			}
+
+			var fval float64
+			if f == "status" {
+				fval = haproxyStatus(v)
+			} else {
+				fval, err = strconv.ParseFloat(v, 64)
+				if err != nil {
+					continue
+				}
+			}
+
+			stats[fmt.Sprintf("%s.%s.%s", sanitizeName(px), sanitizeName(sv), f)] = fval
+		}
+	}
+
+	return stats, nil
+}
+
+func (hap *HAProxy) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	stats, err := hap.stats()
+	metrics = append(metrics, SectionMetrics("haproxy", err, now)...)
+	if err != nil {
+		return hap.config.Apply(metrics, now), err
+	}
+
+	if len(hap.config.Metrics) == 0 {
+		for _, m := range AllMetrics(stats, now) {
+			m.Name = fmt.Sprintf("haproxy.%s", m.Name)
+			metrics = append(metrics, m)
+		}
+	}
+
+	for _, m := range hap.config.Metrics {
+		metrics = append(metrics, m.Metrics(stats, now)...)
+	}
+
+	return hap.config.Apply(metrics, now), err
+}
+
+func (hap *HAProxy) Teardown() {
+
+}
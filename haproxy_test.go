@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHAProxyStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []map[string]string
+		wantErr bool
+	}{
+		{
+			name: "frontend, server and backend",
+			text: `# pxname,svname,qcur,scur,status,
+web,FRONTEND,,3,OPEN,
+web,app1,0,2,UP 1/3,
+web,BACKEND,0,2,UP,
+`,
+			want: []map[string]string{
+				{"pxname": "web", "svname": "FRONTEND", "qcur": "", "scur": "3", "status": "OPEN"},
+				{"pxname": "web", "svname": "app1", "qcur": "0", "scur": "2", "status": "UP 1/3"},
+				{"pxname": "web", "svname": "BACKEND", "qcur": "0", "scur": "2", "status": "UP"},
+			},
+		},
+		{
+			name: "rows longer than the header",
+			text: `# pxname,svname,scur
+web,FRONTEND,3,extra
+`,
+			want: []map[string]string{
+				{"pxname": "web", "svname": "FRONTEND", "scur": "3"},
+			},
+		},
+		{
+			name: "header only",
+			text: "# pxname,svname,scur\n",
+		},
+		{
+			name:    "missing header",
+			text:    "web,FRONTEND,3\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			text:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ParseHAProxyStat(strings.NewReader(tt.text))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %v, want %v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestHAProxyStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   float64
+	}{
+		{"UP", 1},
+		{"UP 1/3", 1},
+		{"OPEN", 1},
+		{"no check", 1},
+		{"DOWN", 0},
+		{"DOWN 1/2", 0},
+		{"MAINT", 0},
+		{"NOLB", 0},
+	}
+
+	for _, tt := range tests {
+		if got := haproxyStatus(tt.status); got != tt.want {
+			t.Errorf("haproxyStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type HTTPJSON struct {
+	client *http.Client
+	config HTTPJSONConfig
+	log    Logger
+}
+
+type HTTPJSONConfig struct {
+	URL      string                  `toml:"url"`
+	Method   string                  `toml:"method"`
+	Headers  map[string]string       `toml:"headers"`
+	User     string                  `toml:"user"`
+	Password string                  `toml:"password"`
+	Timeout  int                     `toml:"timeout"`
+	Metrics  []HTTPJSONMetricsConfig `toml:"metrics"`
+	TLSConfig
+	TimestampConfig
+}
+
+type TLSConfig struct {
+	CACert             string `toml:"ca_cert"`
+	ClientCert         string `toml:"client_cert"`
+	ClientKey          string `toml:"client_key"`
+	ServerName         string `toml:"server_name"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+}
+
+// HTTPJSONMetricsConfig selects values by a dotted JSON path in Name, e.g. "status" or "queues.*.messages".
+// "*" expands to every key of an object or every element of an array, array elements are named
+// by their ArrayKey field ("name" by default) or by their index.
+// String values are converted with ValueMap (e.g. green = 0, yellow = 1, red = 2).
+type HTTPJSONMetricsConfig struct {
+	ArrayKey string             `toml:"array_key"`
+	ValueMap map[string]float64 `toml:"value_map"`
+	MetricsConfig
+}
+
+func NewHTTPJSON(hjConfig HTTPJSONConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var hj *HTTPJSON
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return hj, err
+	}
+
+	var config HTTPJSONConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return hj, err
+	}
+
+	if hjConfig.URL != "" {
+		config.URL = hjConfig.URL
+	}
+
+	if hjConfig.User != "" {
+		config.User = hjConfig.User
+	}
+
+	if hjConfig.Password != "" {
+		config.Password = hjConfig.Password
+	}
+
+	if hjConfig.Timeout > 0 {
+		config.Timeout = hjConfig.Timeout
+	} else if config.Timeout == 0 {
+		config.Timeout = 10
+	}
+
+	if config.Method == "" {
+		config.Method = "GET"
+	}
+
+	if config.URL == "" {
+		return hj, errors.New("url is required")
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return hj, err
+	}
+
+	var client *http.Client
+	client, err = NewHTTPClient(config.TLSConfig, config.Timeout)
+
+	hj = &HTTPJSON{
+		client: client,
+		config: config,
+		log:    log,
+	}
+
+	return hj, err
+}
+
+func (tc TLSConfig) Load() (*tls.Config, error) {
+	var err error
+	c := &tls.Config{
+		ServerName:         tc.ServerName,
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+
+	if tc.CACert != "" {
+		var pem []byte
+		pem, err = ioutil.ReadFile(tc.CACert)
+		if err != nil {
+			return c, err
+		}
+
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return c, fmt.Errorf("No certificates found in %s", tc.CACert)
+		}
+	}
+
+	if tc.ClientCert != "" {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(tc.ClientCert, tc.ClientKey)
+		if err != nil {
+			return c, err
+		}
+
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, err
+}
+
+func NewHTTPClient(tlsConfig TLSConfig, timeout int) (*http.Client, error) {
+	c, err := tlsConfig.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: c,
+		},
+	}, nil
+}
+
+// getJSON sends req and decodes the JSON response into v.
+func getJSON(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+func (hj *HTTPJSON) fetch() (interface{}, error) {
+	var data interface{}
+
+	req, err := http.NewRequest(hj.config.Method, hj.config.URL, nil)
+	if err != nil {
+		return data, err
+	}
+
+	for k, v := range hj.config.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	if hj.config.User != "" {
+		req.SetBasicAuth(hj.config.User, hj.config.Password)
+	}
+
+	err = getJSON(hj.client, req, &data)
+
+	return data, err
+}
+
+// LookupJSONPath returns the values matching path keyed by their concrete path.
+func LookupJSONPath(data interface{}, path []string, arrayKey string) map[string]interface{} {
+	values := make(map[string]interface{})
+	lookupJSONPath(data, path, arrayKey, nil, values)
+
+	return values
+}
+
+func lookupJSONPath(data interface{}, path []string, arrayKey string, prefix []string, values map[string]interface{}) {
+	if len(path) == 0 {
+		values[strings.Join(prefix, ".")] = data
+		return
+	}
+
+	key, rest := path[0], path[1:]
+
+	switch d := data.(type) {
+	case map[string]interface{}:
+		if key != "*" {
+			if v, ok := d[key]; ok {
+				lookupJSONPath(v, rest, arrayKey, append(append([]string{}, prefix...), key), values)
+			}
+			return
+		}
+
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			lookupJSONPath(d[k], rest, arrayKey, append(append([]string{}, prefix...), k), values)
+		}
+	case []interface{}:
+		for i, v := range d {
+			name := strconv.Itoa(i)
+			if obj, ok := v.(map[string]interface{}); ok && arrayKey != "" {
+				if k, ok := obj[arrayKey]; ok {
+					name = fmt.Sprint(k)
+				}
+			}
+
+			if key == "*" || key == name {
+				lookupJSONPath(v, rest, arrayKey, append(append([]string{}, prefix...), name), values)
+			}
+		}
+	}
+}
+
+// JSONValue converts a decoded JSON value into a float64.
+func JSONValue(v interface{}, valueMap map[string]float64) (float64, bool) {
+	switch val := v.(type) {
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	case float64:
+		return val, true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if f, ok := valueMap[val]; ok {
+			return f, true
+		}
+		f, err := strconv.ParseFloat(val, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+func (hj *HTTPJSON) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0, len(hj.config.Metrics)+1)
+
+	data, err := hj.fetch()
+	metrics = append(metrics, SectionMetrics("http_json", err, now)...)
+	if err != nil {
+		return hj.config.Apply(metrics, now), err
+	}
+
+	for _, m := range hj.config.Metrics {
+		arrayKey := m.ArrayKey
+		if arrayKey == "" {
+			arrayKey = "name"
+		}
+
+		names := m.SplitName()
+		for _, n := range names {
+			values := LookupJSONPath(data, strings.Split(n, "."), arrayKey)
+
+			paths := make([]string, 0, len(values))
+			for p := range values {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+
+			for _, p := range paths {
+				value, ok := JSONValue(values[p], m.ValueMap)
+				if !ok {
+					hj.log.Debug(fmt.Sprintf("%s: not a number: %v", p, values[p]))
+					continue
+				}
+
+				// an alias only names a single value, expanded wildcards keep their path
+				name := m.CreateName(p)
+				if p != n && m.Alias != "" {
+					name = fmt.Sprintf("%s.%s", m.Alias, p)
+				}
+
+				metrics = append(metrics, Metric{
+					Name:  name,
+					Value: m.CalcValue(value),
+					Time:  now,
+				})
+			}
+		}
+	}
+
+	return hj.config.Apply(metrics, now), err
+}
+
+func (hj *HTTPJSON) Teardown() {
+
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const httpJSONDocument = `{
+  "status": "green",
+  "nodes": {
+    "a": {"heap": {"used": 10}},
+    "b": {"heap": {"used": 20}}
+  },
+  "queues": [
+    {"name": "mail", "size": 3},
+    {"name": "jobs", "size": 7},
+    {"size": 1}
+  ],
+  "matrix": [[1, 2], [3]]
+}`
+
+func TestLookupJSONPath(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(httpJSONDocument))
+	decoder.UseNumber()
+
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		arrayKey string
+		want     map[string]interface{}
+	}{
+		{
+			name: "plain key",
+			path: "status",
+			want: map[string]interface{}{"status": "green"},
+		},
+		{
+			name: "wildcard object key",
+			path: "nodes.*.heap.used",
+			want: map[string]interface{}{
+				"nodes.a.heap.used": json.Number("10"),
+				"nodes.b.heap.used": json.Number("20"),
+			},
+		},
+		{
+			name:     "array elements named by the array key or their index",
+			path:     "queues.*.size",
+			arrayKey: "name",
+			want: map[string]interface{}{
+				"queues.mail.size": json.Number("3"),
+				"queues.jobs.size": json.Number("7"),
+				"queues.2.size":    json.Number("1"),
+			},
+		},
+		{
+			name:     "array element selected by name",
+			path:     "queues.jobs.size",
+			arrayKey: "name",
+			want:     map[string]interface{}{"queues.jobs.size": json.Number("7")},
+		},
+		{
+			name: "array element selected by index without an array key",
+			path: "queues.1.size",
+			want: map[string]interface{}{"queues.1.size": json.Number("7")},
+		},
+		{
+			name: "nested arrays",
+			path: "matrix.*.*",
+			want: map[string]interface{}{
+				"matrix.0.0": json.Number("1"),
+				"matrix.0.1": json.Number("2"),
+				"matrix.1.0": json.Number("3"),
+			},
+		},
+		{
+			name: "missing key",
+			path: "nodes.c.heap.used",
+			want: map[string]interface{}{},
+		},
+		{
+			name: "path below a scalar",
+			path: "status.color",
+			want: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LookupJSONPath(data, strings.Split(tt.path, "."), tt.arrayKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	valueMap := map[string]float64{"green": 0, "yellow": 1, "red": 2}
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		want   float64
+		wantOK bool
+	}{
+		{name: "number", value: json.Number("1.5"), want: 1.5, wantOK: true},
+		{name: "float", value: 2.0, want: 2, wantOK: true},
+		{name: "true", value: true, want: 1, wantOK: true},
+		{name: "false", value: false, want: 0, wantOK: true},
+		{name: "mapped string", value: "red", want: 2, wantOK: true},
+		{name: "numeric string", value: "42", want: 42, wantOK: true},
+		{name: "other string", value: "blue"},
+		{name: "null", value: nil},
+		{name: "object", value: map[string]interface{}{"a": 1}},
+		{name: "array", value: []interface{}{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := JSONValue(tt.value, valueMap)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("got %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
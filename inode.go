@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"os"
+)
+
+// fileInode is only supported on linux, rotated files are then detected by truncation only.
+func fileInode(fi os.FileInfo) uint64 {
+	return 0
+}
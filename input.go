@@ -4,3 +4,10 @@ type Input interface {
 	FetchMetrics() ([]Metric, error)
 	Teardown()
 }
+
+// Listener is implemented by inputs that receive metrics pushed to them (daemon mode).
+// Listen blocks until Teardown is called and passes the aggregated metrics to flush
+// every flush interval.
+type Listener interface {
+	Listen(flush func([]Metric)) error
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"regexp"
+	"strconv"
+)
+
+type LogTail struct {
+	config LogTailConfig
+	log    Logger
+}
+
+// LogTailConfig reads the lines appended to Path since the previous run, a rotated log is read to its end
+// if it is still next to Path (e.g. Path.1).
+// Metrics are named <prefix>.<rule>.<stat>, the prefix defaults to "logtail".
+type LogTailConfig struct {
+	Path          string        `toml:"path"`
+	StatePath     string        `toml:"state_path"`
+	ReadFromStart bool          `toml:"read_from_start"`
+	Prefix        string        `toml:"prefix"`
+	Rules         []LogTailRule `toml:"rules"`
+	TimestampConfig
+}
+
+// LogTailRule counts the lines matching Pattern.
+// Value names a captured group (or its index) whose numbers are summed up and averaged,
+// GroupBy names a captured group whose values split the stats, e.g. <prefix>.<rule>.<value>.count.
+type LogTailRule struct {
+	Name    string `toml:"name"`
+	Pattern string `toml:"pattern"`
+	Value   string `toml:"value"`
+	GroupBy string `toml:"group_by"`
+	pattern *regexp.Regexp
+	value   int
+	groupBy int
+}
+
+type logTailStat struct {
+	count float64
+	sum   float64
+}
+
+func NewLogTail(ltConfig LogTailConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var lt *LogTail
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return lt, err
+	}
+
+	var config LogTailConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return lt, err
+	}
+
+	if ltConfig.Path != "" {
+		config.Path = ltConfig.Path
+	}
+
+	if config.Path == "" {
+		return lt, errors.New("path is required")
+	}
+
+	if ltConfig.StatePath != "" {
+		config.StatePath = ltConfig.StatePath
+	} else if config.StatePath == "" {
+		config.StatePath = DefaultStatePath("logtail")
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = "logtail"
+	}
+
+	for i := range config.Rules {
+		r := &config.Rules[i]
+
+		if r.Name == "" || r.Pattern == "" {
+			return lt, fmt.Errorf("rules[%d]: name and pattern are required", i)
+		}
+
+		r.pattern, err = regexp.Compile(r.Pattern)
+		if err != nil {
+			return lt, err
+		}
+
+		r.value, err = subexpIndex(r.pattern, r.Value)
+		if err != nil {
+			return lt, fmt.Errorf("rules[%d]: %s", i, err)
+		}
+
+		r.groupBy, err = subexpIndex(r.pattern, r.GroupBy)
+		if err != nil {
+			return lt, fmt.Errorf("rules[%d]: %s", i, err)
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return lt, err
+	}
+
+	lt = &LogTail{
+		config: config,
+		log:    log,
+	}
+
+	return lt, err
+}
+
+// subexpIndex returns the index of a captured group given by name or number, -1 if group is empty.
+func subexpIndex(re *regexp.Regexp, group string) (int, error) {
+	if group == "" {
+		return -1, nil
+	}
+
+	if i, err := strconv.Atoi(group); err == nil {
+		if i < 1 || i > re.NumSubexp() {
+			return -1, fmt.Errorf("%s: no such group: %s", re, group)
+		}
+		return i, nil
+	}
+
+	for i, name := range re.SubexpNames() {
+		if name == group {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("%s: no such group: %s", re, group)
+}
+
+// match adds a line matching the rule to stats keyed by group ("" without GroupBy).
+func (r *LogTailRule) match(line string, stats map[string]*logTailStat) {
+	m := r.pattern.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+
+	var group string
+	if r.groupBy > 0 {
+		group = sanitizeName(m[r.groupBy])
+		if group == "" {
+			group = "none"
+		}
+	}
+
+	s, ok := stats[group]
+	if !ok {
+		s = &logTailStat{}
+		stats[group] = s
+	}
+
+	s.count++
+	if r.value > 0 {
+		v, err := strconv.ParseFloat(m[r.value], 64)
+		if err == nil {
+			s.sum += v
+		}
+	}
+}
+
+func (lt *LogTail) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	state, err := NewState(lt.config.StatePath)
+	if err != nil {
+		return metrics, err
+	}
+	defer state.Close()
+
+	stats := make([]map[string]*logTailStat, len(lt.config.Rules))
+	for i := range stats {
+		stats[i] = make(map[string]*logTailStat)
+	}
+
+	err = TailFile(&state, lt.config.Path, lt.config.ReadFromStart, func(line string) {
+		for i := range lt.config.Rules {
+			lt.config.Rules[i].match(line, stats[i])
+		}
+	})
+	metrics = append(metrics, SectionMetrics(lt.config.Prefix, err, now)...)
+	if err != nil {
+		return lt.config.Apply(metrics, now), err
+	}
+
+	for i, r := range lt.config.Rules {
+		// a rule without matches still reports zero, groups only appear once they matched
+		if r.groupBy < 0 && len(stats[i]) == 0 {
+			stats[i][""] = &logTailStat{}
+		}
+
+		values := make(map[string]float64)
+		for group, s := range stats[i] {
+			name := r.Name
+			if group != "" {
+				name = fmt.Sprintf("%s.%s", r.Name, group)
+			}
+
+			values[fmt.Sprintf("%s.count", name)] = s.count
+			if r.value > 0 {
+				values[fmt.Sprintf("%s.sum", name)] = s.sum
+				if s.count > 0 {
+					values[fmt.Sprintf("%s.avg", name)] = s.sum / s.count
+				}
+			}
+		}
+
+		for _, key := range sortedKeys(values) {
+			metrics = append(metrics, Metric{
+				Name:  fmt.Sprintf("%s.%s", lt.config.Prefix, key),
+				Value: values[key],
+				Time:  now,
+			})
+		}
+	}
+
+	return lt.config.Apply(metrics, now), err
+}
+
+func (lt *LogTail) Teardown() {
+
+}
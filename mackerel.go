@@ -54,11 +54,18 @@ func (m *Mackerel) Send(metrics []Metric) error {
 	var err error
 
 	mkrMetrics := make([]*mkr.MetricValue, 0, len(metrics))
-	for _, m := range metrics {
+	for _, metric := range metrics {
+		// Mackerel only accepts numbers, text values (e.g. zk_server_state) are skipped
+		value, ok := metric.Value.(float64)
+		if !ok {
+			m.log.Debug("skip non-numeric metric: ", metric.Name)
+			continue
+		}
+
 		mkrMetrics = append(mkrMetrics, &mkr.MetricValue{
-			Name:  m.Name,
-			Time:  m.Time.Unix(),
-			Value: m.Value.(float64),
+			Name:  metric.Name,
+			Time:  metric.Time.Unix(),
+			Value: value,
 		})
 	}
 	err = m.client.PostServiceMetricValues(m.config.Service, mkrMetrics)
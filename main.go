@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 )
 
 var (
@@ -16,7 +19,7 @@ var (
 	outType    = kingpin.Flag("output-type", "Output type").String()
 	logFile    = kingpin.Flag("logfile", "Logfile").String()
 	logLevel   = kingpin.Flag("loglevel", "Loglevel").String()
-	timezone   = kingpin.Flag("timezone", "Timezone").String()
+	timezone   = kingpin.Flag("timezone", "Timezone used to display timestamps").String()
 
 	inPort   = kingpin.Flag("input-port", "Input port").Int()
 	outPort  = kingpin.Flag("output-port", "Output port").Int()
@@ -25,6 +28,17 @@ var (
 	password = kingpin.Flag("password", "Password").String()
 	timeout  = kingpin.Flag("timeout", "Timeout").Default("5").Int()
 
+	concurrency = kingpin.Flag("concurrency", "Number of parallel fetches").Int()
+
+	// sampling
+	samples           = kingpin.Flag("samples", "Number of samples per run").Int()
+	sampleInterval    = kingpin.Flag("sample-interval", "Interval between samples (e.g. 10s, a minute divided by the samples by default)").String()
+	sampleAggregation = kingpin.Flag("sample-aggregation", "Aggregate samples (min, max, avg, sum, count, last)").Strings()
+
+	// splay
+	splay     = kingpin.Flag("splay", "Maximum delay before fetching (e.g. 30s)").String()
+	splayMode = kingpin.Flag("splay-mode", "Splay mode (hash, random)").String()
+
 	// cloudwatch
 	awsAccessKeyID     = kingpin.Flag("access-key", "AWS access key ID").String()
 	awsSecretAccessKey = kingpin.Flag("secret-key", "AWS secret access key").String()
@@ -35,6 +49,20 @@ var (
 	// command
 	cmd = kingpin.Flag("command", "Command").String()
 
+	// memcached, haproxy, container
+	socket = kingpin.Flag("socket", "Unix socket path").String()
+
+	// http_json, prometheus, mongodb, haproxy, rabbitmq
+	inURL = kingpin.Flag("url", "URL").String()
+	user  = kingpin.Flag("user", "User").String()
+
+	// statsd, graphite
+	listen        = kingpin.Flag("listen", "Listen address (UDP for statsd, TCP for graphite)").String()
+	flushInterval = kingpin.Flag("flush-interval", "Flush interval (e.g. 60s)").String()
+
+	// logtail, accesslog, mysql_slowlog
+	logPath = kingpin.Flag("path", "Log file path").String()
+
 	// mackerel
 	mkrAPIKey = kingpin.Flag("mackerel-api-key", "Mackerel API Key").String()
 
@@ -44,6 +72,8 @@ var (
 )
 
 func main() {
+	start := time.Now()
+
 	kingpin.Version("0.1.0")
 	kingpin.Parse()
 
@@ -52,11 +82,17 @@ func main() {
 	log := NewLogger()
 
 	argConfig := Config{
-		InputType:  *inType,
-		OutputType: *outType,
-		LogFile:    *logFile,
-		LogLevel:   *logLevel,
-		Target:     *target,
+		InputType:         *inType,
+		OutputType:        *outType,
+		LogFile:           *logFile,
+		LogLevel:          *logLevel,
+		Timezone:          *timezone,
+		Target:            *target,
+		Samples:           *samples,
+		SampleInterval:    *sampleInterval,
+		SampleAggregation: *sampleAggregation,
+		Splay:             *splay,
+		SplayMode:         *splayMode,
 	}
 
 	config, err := LoadConfig(argConfig, *configFile)
@@ -67,6 +103,11 @@ func main() {
 
 	log.Setup(config.LogLevel, config.LogFile)
 
+	loc, err := LoadTimezone(config.Timezone)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	var input Input
 	switch config.InputType {
 	case "cloudwatch":
@@ -76,30 +117,154 @@ func main() {
 			Token:              *token,
 			Profile:            *profile,
 			Credentials:        *creds,
-			Timezone:           *timezone,
+			Concurrency:        *concurrency,
 		}
 		input, err = NewCloudWatch(cwConfig, *inputConf, log)
 	case "mysql":
 		mysqlConfig := MySQLConfig{
-			Host:     *inHost,
-			Port:     *inPort,
+			Host:        *inHost,
+			Port:        *inPort,
+			Password:    *password,
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		input, err = NewMySQL(mysqlConfig, *inputConf, log)
+	case "postgresql":
+		pgConfig := PostgreSQLConfig{
+			Host:        *inHost,
+			Port:        *inPort,
+			Password:    *password,
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		input, err = NewPostgreSQL(pgConfig, *inputConf, log)
+	case "sql":
+		sqlConfig := SQLConfig{
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		input, err = NewSQL(sqlConfig, *inputConf, log)
+	case "mongodb":
+		mongoConfig := MongoDBConfig{
+			Host:        *inHost,
+			Port:        *inPort,
+			User:        *user,
+			Password:    *password,
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		input, err = NewMongoDB(mongoConfig, *inputConf, log)
+	case "haproxy":
+		hapConfig := HAProxyConfig{
+			Socket:   *socket,
+			URL:      *inURL,
+			User:     *user,
 			Password: *password,
 			Timeout:  *timeout,
-			Timezone: *timezone,
 		}
-		input, err = NewMySQL(mysqlConfig, *inputConf, log)
+		input, err = NewHAProxy(hapConfig, *inputConf, log)
+	case "rabbitmq":
+		rmqConfig := RabbitMQConfig{
+			URL:         *inURL,
+			User:        *user,
+			Password:    *password,
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		input, err = NewRabbitMQ(rmqConfig, *inputConf, log)
+	case "zookeeper":
+		zkConfig := ZooKeeperConfig{
+			Host:    *inHost,
+			Port:    *inPort,
+			Timeout: *timeout,
+		}
+		input, err = NewZooKeeper(zkConfig, *inputConf, log)
 	case "redis":
 		redisConfig := RedisConfig{
 			Port:     *inPort,
 			Password: *password,
 			Timeout:  *timeout,
-			Timezone: *timezone,
 		}
 		input, err = NewRedis(redisConfig, *inputConf, log)
+	case "memcached":
+		mcConfig := MemcachedConfig{
+			Host:    *inHost,
+			Port:    *inPort,
+			Socket:  *socket,
+			Timeout: *timeout,
+		}
+		input, err = NewMemcached(mcConfig, *inputConf, log)
+	case "http_json":
+		hjConfig := HTTPJSONConfig{
+			URL:      *inURL,
+			User:     *user,
+			Password: *password,
+			Timeout:  *timeout,
+		}
+		input, err = NewHTTPJSON(hjConfig, *inputConf, log)
+	case "prometheus":
+		promConfig := PrometheusConfig{
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		if *inURL != "" {
+			promConfig.URLs = []string{*inURL}
+		}
+		input, err = NewPrometheus(promConfig, *inputConf, log)
+	case "system":
+		input, err = NewSystem(SystemConfig{}, *inputConf, log)
+	case "process":
+		input, err = NewProcess(ProcessConfig{}, *inputConf, log)
+	case "statsd":
+		sdConfig := StatsDConfig{
+			UDPAddress:    *listen,
+			FlushInterval: *flushInterval,
+		}
+		input, err = NewStatsD(sdConfig, *inputConf, log)
+	case "graphite":
+		gConfig := GraphiteConfig{
+			TCPAddress:    *listen,
+			FlushInterval: *flushInterval,
+		}
+		input, err = NewGraphite(gConfig, *inputConf, log)
+	case "logtail":
+		ltConfig := LogTailConfig{
+			Path: *logPath,
+		}
+		input, err = NewLogTail(ltConfig, *inputConf, log)
+	case "accesslog":
+		alConfig := AccessLogConfig{
+			Path: *logPath,
+		}
+		input, err = NewAccessLog(alConfig, *inputConf, log)
+	case "mysql_slowlog":
+		slConfig := MySQLSlowlogConfig{
+			Path: *logPath,
+		}
+		input, err = NewMySQLSlowlog(slConfig, *inputConf, log)
+	case "container":
+		ctConfig := ContainerConfig{
+			DockerSocket: *socket,
+			Timeout:      *timeout,
+		}
+		input, err = NewContainer(ctConfig, *inputConf, log)
+	case "file":
+		input, err = NewFile(FileConfig{}, *inputConf, log)
+	case "check":
+		checkConfig := CheckConfig{
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		input, err = NewCheck(checkConfig, *inputConf, log)
+	case "cert":
+		certConfig := CertConfig{
+			Timeout:     *timeout,
+			Concurrency: *concurrency,
+		}
+		input, err = NewCert(certConfig, *inputConf, log)
 	case "command":
 		cmdConfig := CommandConfig{
-			Command:  *cmd,
-			Timezone: *timezone,
+			Command: *cmd,
 		}
 		input, err = NewCommand(cmdConfig, *inputConf, log)
 	default:
@@ -137,50 +302,76 @@ func main() {
 		log.Fatal(err)
 	}
 
-	metrics, err := input.FetchMetrics()
-	if err != nil {
-		log.Fatal(err)
+	sender := NewSender(output, bPath, *bufferMode, config.InputType, log)
+	defer sender.Close()
+
+	// daemon mode, metrics are sent every flush interval until the process is stopped
+	if listener, ok := input.(Listener); ok {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sig
+			input.Teardown()
+		}()
+
+		err = listener.Listen(func(metrics []Metric) {
+			log.Debug("metrics: ", InTimezone(metrics, loc))
+			if err := sender.Send(metrics); err != nil {
+				log.Error(err)
+			}
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	log.Debug("metrics: ", metrics)
 
-	buffer, bufErr := NewBuffer(bPath, *bufferMode)
-	defer buffer.Close()
-	bucket := config.InputType
+	interval := DefaultSampleInterval(config.Samples)
+	if config.SampleInterval != "" {
+		interval, err = time.ParseDuration(config.SampleInterval)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	var bufferedMetrics map[string][]Metric
-	bufferedMetrics, err = buffer.Read(bucket, 10)
+		if interval <= 0 && config.Samples > 1 {
+			log.Fatal(fmt.Sprintf("Invalid sample_interval: %s", config.SampleInterval))
+		}
+	}
 
-	if err != nil {
-		if err.Error() != "Bucket not found" {
-			log.Warn(err)
-		}
-	} else {
-		if len(bufferedMetrics) > 0 {
-			for key, ms := range bufferedMetrics {
-				err = output.Send(ms)
-				if err != nil {
-					log.Warn(err)
-				} else {
-					err = buffer.Delete(bucket, key)
-					log.Debug(err)
-				}
-			}
+	if config.Splay != "" {
+		var window, delay time.Duration
+		window, err = time.ParseDuration(config.Splay)
+		if err != nil {
+			log.Fatal(err)
+		}
 
+		delay, err = SplayDelay(window, config.SplayMode, config.Target)
+		if err != nil {
+			log.Fatal(err)
 		}
+
+		log.Debug("splay: ", delay)
+		Splay(delay)
 	}
-	log.Debug("bufferd metrics: ", bufferedMetrics)
 
-	err = output.Send(metrics)
+	ScheduleRun(start)
+	metrics, err := FetchSamples(input, config.Samples, interval, log)
 	if err != nil {
-		if bufErr != nil {
-			log.Warn(err)
-		} else {
-			err = buffer.Write(bucket, metrics)
-			if err != nil {
-				log.Warn(err)
-			}
+		// send whatever could be collected, the failed sections are reported as *.up = 0
+		log.Error(err)
+	}
+	log.Debug("metrics: ", InTimezone(metrics, loc))
+
+	if len(config.SampleAggregation) > 0 {
+		metrics, err = AggregateSamples(metrics, config.SampleAggregation)
+		if err != nil {
+			log.Fatal(err)
 		}
+		log.Debug("aggregated metrics: ", InTimezone(metrics, loc))
+	}
 
+	err = sender.Send(metrics)
+	if err != nil {
 		log.Fatal(err)
 	}
 }
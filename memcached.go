@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Memcached struct {
+	config MemcachedConfig
+	log    Logger
+}
+
+type MemcachedConfig struct {
+	Host    string                   `toml:"host"`
+	Port    int                      `toml:"port"`
+	Socket  string                   `toml:"socket"`
+	Timeout int                      `toml:"timeout"`
+	Metrics []MemcachedMetricsConfig `toml:"metrics"`
+	TimestampConfig
+}
+
+type MemcachedMetricsConfig struct {
+	MetricsConfig
+}
+
+func NewMemcached(mcConfig MemcachedConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var mc *Memcached
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return mc, err
+	}
+
+	var config MemcachedConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return mc, err
+	}
+
+	if mcConfig.Host != "" {
+		config.Host = mcConfig.Host
+	}
+
+	if mcConfig.Port != 0 {
+		config.Port = mcConfig.Port
+	} else if config.Port == 0 {
+		config.Port = 11211
+	}
+
+	if mcConfig.Socket != "" {
+		config.Socket = mcConfig.Socket
+	}
+
+	if mcConfig.Timeout > 0 {
+		config.Timeout = mcConfig.Timeout
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return mc, err
+	}
+
+	mc = &Memcached{
+		config: config,
+		log:    log,
+	}
+
+	return mc, err
+}
+
+func (mc *Memcached) dial() (net.Conn, error) {
+	timeout := time.Duration(mc.config.Timeout) * time.Second
+
+	if mc.config.Socket != "" {
+		return net.DialTimeout("unix", mc.config.Socket, timeout)
+	}
+
+	return net.DialTimeout("tcp", fmt.Sprintf("%s:%d", mc.config.Host, mc.config.Port), timeout)
+}
+
+// command sends a stats command and returns the STAT lines as name/value pairs.
+func (mc *Memcached) command(conn net.Conn, r *bufio.Reader, cmd string) ([][2]string, error) {
+	var err error
+	var stats [][2]string
+
+	if mc.config.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(time.Duration(mc.config.Timeout) * time.Second))
+	}
+
+	_, err = fmt.Fprintf(conn, "%s\r\n", cmd)
+	if err != nil {
+		return stats, err
+	}
+
+	stats = make([][2]string, 0)
+	for {
+		var line string
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return stats, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "END" {
+			break
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "STAT" {
+			return stats, fmt.Errorf("%s: unexpected response: %s", cmd, line)
+		}
+
+		stats = append(stats, [2]string{fields[1], fields[2]})
+	}
+
+	return stats, err
+}
+
+// stats collects "stats", "stats slabs" and "stats items".
+// Per-slab stats are named like slab_1_used_chunks and items_1_number,
+// the per-slab item stats are also summed up as items_number.
+func (mc *Memcached) stats() (map[string]float64, error) {
+	var err error
+	var stats map[string]float64
+
+	conn, err := mc.dial()
+	if err != nil {
+		return stats, err
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	stats = make(map[string]float64)
+
+	for _, cmd := range []string{"stats", "stats slabs", "stats items"} {
+		var lines [][2]string
+		lines, err = mc.command(conn, r, cmd)
+		if err != nil {
+			return stats, err
+		}
+
+		for _, kv := range lines {
+			key, value := kv[0], kv[1]
+
+			fval, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			switch cmd {
+			case "stats slabs":
+				if strings.Contains(key, ":") {
+					key = fmt.Sprintf("slab_%s", strings.Replace(key, ":", "_", -1))
+				}
+			case "stats items":
+				parts := strings.SplitN(key, ":", 3)
+				if len(parts) == 3 {
+					key = fmt.Sprintf("items_%s_%s", parts[1], parts[2])
+					stats[fmt.Sprintf("items_%s", parts[2])] += fval
+				}
+			}
+
+			stats[key] = fval
+		}
+	}
+
+	return stats, err
+}
+
+func (mc *Memcached) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0, len(mc.config.Metrics)+1)
+
+	stats, err := mc.stats()
+	metrics = append(metrics, SectionMetrics("memcached", err, now)...)
+	if err != nil {
+		return mc.config.Apply(metrics, now), err
+	}
+
+	for _, m := range mc.config.Metrics {
+		metrics = append(metrics, m.Metrics(stats, now)...)
+	}
+
+	return mc.config.Apply(metrics, now), err
+}
+
+func (mc *Memcached) Teardown() {
+
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"path"
+	"sort"
 	"strings"
 	"time"
 )
@@ -63,3 +65,136 @@ func (mc *MetricsConfig) CalcValue(value float64) float64 {
 
 	return value
 }
+
+// Metrics converts the stats selected by the config into metrics.
+// Names may be glob patterns (e.g. "disk.*.reads_completed"), stats the input did not report are skipped.
+func (mc *MetricsConfig) Metrics(stats map[string]float64, now time.Time) []Metric {
+	names := mc.SplitName()
+	metrics := make([]Metric, 0, len(names))
+
+	for _, n := range names {
+		if !strings.ContainsAny(n, "*?[") {
+			value, ok := stats[n]
+			if !ok {
+				continue
+			}
+
+			metrics = append(metrics, Metric{
+				Name:  mc.CreateName(n),
+				Value: mc.CalcValue(value),
+				Time:  now,
+			})
+			continue
+		}
+
+		for _, key := range sortedKeys(stats) {
+			if ok, _ := path.Match(n, key); !ok {
+				continue
+			}
+
+			// an alias only names a single value, matched stats keep their name
+			name := mc.CreateName(key)
+			if mc.Alias != "" {
+				name = fmt.Sprintf("%s.%s", mc.Alias, key)
+			}
+
+			metrics = append(metrics, Metric{
+				Name:  name,
+				Value: mc.CalcValue(stats[key]),
+				Time:  now,
+			})
+		}
+	}
+
+	return metrics
+}
+
+// AllMetrics converts every stat into a metric, sorted by name.
+func AllMetrics(stats map[string]float64, now time.Time) []Metric {
+	metrics := make([]Metric, 0, len(stats))
+	for _, key := range sortedKeys(stats) {
+		metrics = append(metrics, Metric{
+			Name:  key,
+			Value: stats[key],
+			Time:  now,
+		})
+	}
+
+	return metrics
+}
+
+func sortedKeys(stats map[string]float64) []string {
+	keys := make([]string, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// UpMetric reports whether a section of an input could be collected (1) or not (0).
+func UpMetric(name string, err error, now time.Time) Metric {
+	var value float64
+	if err == nil {
+		value = 1
+	}
+
+	return Metric{
+		Name:  fmt.Sprintf("%s.up", name),
+		Value: value,
+		Time:  now,
+	}
+}
+
+// ErrorMetric reports the number of errors of a section of an input, every error of a MultiError counts.
+func ErrorMetric(name string, err error, now time.Time) Metric {
+	var value float64
+	if me, ok := err.(MultiError); ok {
+		value = float64(len(me))
+	} else if err != nil {
+		value = 1
+	}
+
+	return Metric{
+		Name:  fmt.Sprintf("%s.error", name),
+		Value: value,
+		Time:  now,
+	}
+}
+
+// SectionMetrics returns the up and error metrics of a section of an input.
+func SectionMetrics(name string, err error, now time.Time) []Metric {
+	return []Metric{UpMetric(name, err, now), ErrorMetric(name, err, now)}
+}
+
+// IsHealthMetric reports whether name is an up or error metric of SectionMetrics.
+func IsHealthMetric(name string) bool {
+	return strings.HasSuffix(name, ".up") || strings.HasSuffix(name, ".error")
+}
+
+// Select reports whether the config selects the stat called name and returns the metric name,
+// names are matched like in Metrics.
+func (mc *MetricsConfig) Select(name string) (string, bool) {
+	for _, n := range mc.SplitName() {
+		if n == name {
+			return mc.CreateName(n), true
+		}
+
+		if !strings.ContainsAny(n, "*?[") {
+			continue
+		}
+
+		if ok, _ := path.Match(n, name); !ok {
+			continue
+		}
+
+		if mc.Alias != "" {
+			return fmt.Sprintf("%s.%s", mc.Alias, name), true
+		}
+
+		return mc.CreateName(name), true
+	}
+
+	return "", false
+}
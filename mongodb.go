@@ -0,0 +1,337 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"net"
+	"strconv"
+	"time"
+)
+
+type MongoDB struct {
+	config MongoDBConfig
+	log    Logger
+}
+
+// MongoDBConfig selects metrics per section (server_status, repl_set_status, db_stats) like MySQLConfig,
+// nested documents are flattened into dotted names, e.g. server_status "opcounters.query".
+// db_stats are named <database>.<stat> and collected for Databases (all databases by default).
+type MongoDBConfig struct {
+	Host         string                            `toml:"host"`
+	User         string                            `toml:"user"`
+	Port         int                               `toml:"port"`
+	Password     string                            `toml:"password"`
+	AuthDatabase string                            `toml:"auth_database"`
+	Timeout      int                               `toml:"timeout"`
+	Concurrency  int                               `toml:"concurrency"`
+	TLS          bool                              `toml:"tls"`
+	Databases    []string                          `toml:"databases"`
+	Metrics      map[string][]MongoDBMetricsConfig `toml:"metrics"`
+	TLSConfig
+	TimestampConfig
+}
+
+type MongoDBMetricsConfig struct {
+	MetricsConfig
+}
+
+type replSetMember struct {
+	Name       string    `bson:"name"`
+	State      int       `bson:"state"`
+	Health     float64   `bson:"health"`
+	OptimeDate time.Time `bson:"optimeDate"`
+	Self       bool      `bson:"self"`
+}
+
+type replSetStatus struct {
+	MyState int             `bson:"myState"`
+	Members []replSetMember `bson:"members"`
+}
+
+func NewMongoDB(mongoConfig MongoDBConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var mongo *MongoDB
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return mongo, err
+	}
+
+	var config MongoDBConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return mongo, err
+	}
+
+	if mongoConfig.Host != "" {
+		config.Host = mongoConfig.Host
+	} else if config.Host == "" {
+		config.Host = "localhost"
+	}
+
+	if mongoConfig.User != "" {
+		config.User = mongoConfig.User
+	}
+
+	if mongoConfig.Port != 0 {
+		config.Port = mongoConfig.Port
+	} else if config.Port == 0 {
+		config.Port = 27017
+	}
+
+	if mongoConfig.Password != "" {
+		config.Password = mongoConfig.Password
+	}
+
+	if mongoConfig.Timeout > 0 {
+		config.Timeout = mongoConfig.Timeout
+	}
+
+	if mongoConfig.Concurrency > 0 {
+		config.Concurrency = mongoConfig.Concurrency
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return mongo, err
+	}
+
+	mongo = &MongoDB{
+		config: config,
+		log:    log,
+	}
+
+	return mongo, err
+}
+
+func (m *MongoDB) dial() (*mgo.Session, error) {
+	timeout := time.Duration(m.config.Timeout) * time.Second
+
+	info := &mgo.DialInfo{
+		Addrs:    []string{net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))},
+		Direct:   true,
+		Timeout:  timeout,
+		Username: m.config.User,
+		Password: m.config.Password,
+		Source:   m.config.AuthDatabase,
+	}
+
+	if m.config.TLS {
+		c, err := m.config.TLSConfig.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			dialer := &net.Dialer{Timeout: timeout}
+			return tls.DialWithDialer(dialer, "tcp", addr.String(), c)
+		}
+	}
+
+	session, err := mgo.DialWithInfo(info)
+	if err != nil {
+		return nil, err
+	}
+
+	session.SetMode(mgo.Monotonic, true)
+	if timeout > 0 {
+		session.SetSocketTimeout(timeout)
+	}
+
+	return session, nil
+}
+
+// flattenDocument adds the numeric values of a document to stats, nested documents are joined by dots.
+func flattenDocument(prefix string, v interface{}, stats map[string]float64) {
+	key := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return fmt.Sprintf("%s.%s", prefix, k)
+	}
+
+	switch val := v.(type) {
+	case bson.M:
+		for k, e := range val {
+			flattenDocument(key(k), e, stats)
+		}
+	case map[string]interface{}:
+		for k, e := range val {
+			flattenDocument(key(k), e, stats)
+		}
+	case bson.D:
+		for _, e := range val {
+			flattenDocument(key(e.Name), e.Value, stats)
+		}
+	case int:
+		stats[prefix] = float64(val)
+	case int32:
+		stats[prefix] = float64(val)
+	case int64:
+		stats[prefix] = float64(val)
+	case float64:
+		stats[prefix] = val
+	case bool:
+		if val {
+			stats[prefix] = 1
+		} else {
+			stats[prefix] = 0
+		}
+	case time.Time:
+		stats[prefix] = float64(val.Unix())
+	}
+}
+
+func (m *MongoDB) serverStatus(session *mgo.Session) (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	var result bson.M
+	err := session.Run(bson.D{{Name: "serverStatus", Value: 1}}, &result)
+	if err != nil {
+		return stats, err
+	}
+
+	flattenDocument("", result, stats)
+
+	return stats, nil
+}
+
+// replSetStatus reports the state of every member, the lag of a member is the difference of its optime
+// to the optime of the primary.
+func (m *MongoDB) replSetStatus(session *mgo.Session) (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	var status replSetStatus
+	err := session.Run(bson.D{{Name: "replSetGetStatus", Value: 1}}, &status)
+	if err != nil {
+		return stats, err
+	}
+
+	var primary *replSetMember
+	for i, member := range status.Members {
+		if member.State == 1 {
+			primary = &status.Members[i]
+		}
+	}
+
+	stats["state"] = float64(status.MyState)
+	stats["members"] = float64(len(status.Members))
+	stats["members_healthy"] = 0
+
+	for _, member := range status.Members {
+		name := sanitizeName(member.Name)
+		stats[fmt.Sprintf("members.%s.state", name)] = float64(member.State)
+		stats[fmt.Sprintf("members.%s.health", name)] = member.Health
+		stats["members_healthy"] += member.Health
+
+		if primary == nil {
+			continue
+		}
+
+		lag := primary.OptimeDate.Sub(member.OptimeDate).Seconds()
+		if lag < 0 {
+			lag = 0
+		}
+
+		stats[fmt.Sprintf("members.%s.lag", name)] = lag
+		if lag > stats["max_lag"] {
+			stats["max_lag"] = lag
+		}
+
+		if member.Self {
+			stats["lag"] = lag
+		}
+	}
+
+	return stats, nil
+}
+
+// dbStats runs dbStats for every database, databases that fail are left out and returned in a MultiError.
+func (m *MongoDB) dbStats(session *mgo.Session) (map[string]float64, error) {
+	var err error
+	stats := make(map[string]float64)
+
+	databases := m.config.Databases
+	if len(databases) == 0 {
+		databases, err = session.DatabaseNames()
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	var errs MultiError
+	for _, name := range databases {
+		var result bson.M
+		err = session.DB(name).Run(bson.D{{Name: "dbStats", Value: 1}}, &result)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+			continue
+		}
+
+		flattenDocument(sanitizeName(name), result, stats)
+	}
+
+	return stats, errs.ErrorOrNil()
+}
+
+func (m *MongoDB) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	session, err := m.dial()
+	metrics = append(metrics, SectionMetrics("mongodb", err, now)...)
+	if err != nil {
+		return m.config.Apply(metrics, now), err
+	}
+	defer session.Close()
+
+	sections := make([]Section, 0, 3)
+	for _, s := range []struct {
+		name  string
+		fetch func(*mgo.Session) (map[string]float64, error)
+	}{
+		{"server_status", m.serverStatus},
+		{"repl_set_status", m.replSetStatus},
+		{"db_stats", m.dbStats},
+	} {
+		if len(m.config.Metrics[s.name]) == 0 {
+			continue
+		}
+
+		fetch := s.fetch
+		sections = append(sections, Section{
+			Name: s.name,
+			Fetch: func() (map[string]float64, error) {
+				s := session.Copy()
+				defer s.Close()
+
+				return fetch(s)
+			},
+		})
+	}
+
+	stats, errs, err := FetchSections(m.config.Concurrency, sections)
+
+	for i, s := range sections {
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("mongodb.%s", s.Name), errs[i], now)...)
+		// a MultiError comes with the stats of the databases that did not fail
+		if _, partial := errs[i].(MultiError); errs[i] != nil && !partial {
+			continue
+		}
+
+		for _, c := range m.config.Metrics[s.Name] {
+			metrics = append(metrics, c.Metrics(stats[i], now)...)
+		}
+	}
+
+	return m.config.Apply(metrics, now), err
+}
+
+func (m *MongoDB) Teardown() {
+
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
 	"github.com/BurntSushi/toml"
 	_ "github.com/go-sql-driver/mysql"
@@ -18,13 +17,14 @@ type MySQL struct {
 }
 
 type MySQLConfig struct {
-	Host     string                          `toml:"host"`
-	User     string                          `toml:"user"`
-	Port     int                             `toml:"port"`
-	Password string                          `toml:"password"`
-	Timeout  int                             `toml:"timeout"`
-	Timezone string                          `toml:"timezone"`
-	Metrics  map[string][]MySQLMetricsConfig `toml:"metrics"`
+	Host        string                          `toml:"host"`
+	User        string                          `toml:"user"`
+	Port        int                             `toml:"port"`
+	Password    string                          `toml:"password"`
+	Timeout     int                             `toml:"timeout"`
+	Concurrency int                             `toml:"concurrency"`
+	Metrics     map[string][]MySQLMetricsConfig `toml:"metrics"`
+	TimestampConfig
 }
 
 type MySQLMetricsConfig struct {
@@ -70,8 +70,13 @@ func NewMySQL(mysqlConfig MySQLConfig, filename string, log Logger) (Input, erro
 		config.Timeout = mysqlConfig.Timeout
 	}
 
-	if mysqlConfig.Timezone != "" {
-		config.Timezone = mysqlConfig.Timezone
+	if mysqlConfig.Concurrency > 0 {
+		config.Concurrency = mysqlConfig.Concurrency
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return mysql, err
 	}
 
 	var db *sql.DB
@@ -97,21 +102,9 @@ func (m *MySQL) showGlobalStatus() (map[string]float64, error) {
 	if err != nil {
 		return stats, err
 	}
+	defer rows.Close()
 
-	stats = make(map[string]float64)
-	for rows.Next() {
-		var value string
-		var name string
-		var fval float64
-		if err = rows.Scan(&name, &value); err == nil {
-			fval, err = strconv.ParseFloat(string(value), 64)
-			if err != nil {
-				continue
-			}
-
-			stats[name] = fval
-		}
-	}
+	stats, err = fetchKeyValues(rows)
 
 	return stats, err
 }
@@ -125,6 +118,7 @@ func (m *MySQL) showSlaveStatus() (map[string]float64, error) {
 	if err != nil {
 		return stats, err
 	}
+	defer rows.Close()
 
 	stats, err = m.fetchSlaveStatus(rows)
 
@@ -417,45 +411,10 @@ func findStringSubMatch(s, target string, index ...int) []float64 {
 	return data
 }
 
-func (m *MySQL) fetchRows(rows *sql.Rows) (map[string]float64, error) {
-	data := make(map[string]float64)
-	columns, err := rows.Columns()
-	if err != nil {
-		return data, err
-	}
-
-	values := make([]sql.RawBytes, len(columns))
-	scanArgs := make([]interface{}, len(values))
-	for i := range values {
-		scanArgs[i] = &values[i]
-	}
-
-	if !rows.Next() {
-		return data, errors.New("empty rows")
-	}
-
-	err = rows.Scan(scanArgs...)
-	if err != nil {
-		return data, err
-	}
-
-	var fval float64
-	for i, val := range values {
-		fval, err = strconv.ParseFloat(string(val), 64)
-		if err != nil {
-			continue
-		}
-
-		data[columns[i]] = fval
-	}
-
-	return data, err
-}
-
 func (m *MySQL) fetchSlaveStatus(rows *sql.Rows) (map[string]float64, error) {
 	data := make(map[string]float64)
 
-	tmpData, err := m.fetchRows(rows)
+	tmpData, err := fetchRows(rows)
 
 	if err != nil {
 		return data, err
@@ -486,63 +445,39 @@ func setMetrics(metrics *[]Metric, config []MySQLMetricsConfig, stats map[string
 
 func (m *MySQL) FetchMetrics() ([]Metric, error) {
 	var err error
-	var now time.Time
-
-	globalStatusLen := len(m.config.Metrics["global_status"])
-	innodbStatusLen := len(m.config.Metrics["innodb_status"])
-	slaveStatusLen := len(m.config.Metrics["slave_status"])
 
-	globalStatus := make(map[string]float64)
-	innodbStatus := make(map[string]float64)
-	slaveStatus := make(map[string]float64)
+	now := ScheduledNow()
 
-	metrics := make([]Metric, 0)
-
-	now, err = FixedTimezone(time.Now(), m.config.Timezone)
-	if err != nil {
-		m.log.Debug(err)
-	}
-
-	if globalStatusLen > 0 {
-		globalStatus, err = m.showGlobalStatus()
-		if err != nil {
-			return metrics, err
+	sections := make([]Section, 0, 3)
+	for _, s := range []Section{
+		{Name: "global_status", Fetch: m.showGlobalStatus},
+		{Name: "innodb_status", Fetch: m.showEngineInnodbStatus},
+		{Name: "slave_status", Fetch: m.showSlaveStatus},
+	} {
+		if len(m.config.Metrics[s.Name]) > 0 {
+			sections = append(sections, s)
 		}
-
-		if _, ok := globalStatus["Com_select"]; ok && globalStatus["Com_select"] > 0 {
-			globalStatus["Com_select"]--
-		}
-		setMetrics(&metrics, m.config.Metrics["global_status"], globalStatus, now)
 	}
 
-	if innodbStatusLen > 0 {
-		innodbStatus, err = m.showEngineInnodbStatus()
-
-		if err != nil {
-			return metrics, err
-		}
-
-		if _, ok := globalStatus["Com_select"]; ok && globalStatus["Com_select"] > 0 {
-			globalStatus["Com_select"]--
-		}
-
-		setMetrics(&metrics, m.config.Metrics["innodb_status"], innodbStatus, now)
-	}
+	stats, errs, err := FetchSections(m.config.Concurrency, sections)
 
-	if slaveStatusLen > 0 {
-		slaveStatus, err = m.showSlaveStatus()
-		if err != nil {
-			return metrics, err
+	metrics := make([]Metric, 0)
+	for i, s := range sections {
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("mysql.%s", s.Name), errs[i], now)...)
+		if errs[i] != nil {
+			continue
 		}
 
-		if _, ok := globalStatus["Com_select"]; ok && globalStatus["Com_select"] > 0 {
-			globalStatus["Com_select"]--
+		if s.Name == "global_status" {
+			if _, ok := stats[i]["Com_select"]; ok && stats[i]["Com_select"] > 0 {
+				stats[i]["Com_select"]--
+			}
 		}
 
-		setMetrics(&metrics, m.config.Metrics["slave_status"], slaveStatus, now)
+		setMetrics(&metrics, m.config.Metrics[s.Name], stats[i], now)
 	}
 
-	return metrics, err
+	return m.config.Apply(metrics, now), err
 }
 
 func (m *MySQL) Teardown() {
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var slowlogStats = []string{"query_time", "lock_time", "rows_examined"}
+
+var (
+	// string literals and comments are matched together, so that quotes in comments
+	// and comment markers in strings are left alone
+	fingerprintLiterals = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"|(?s:/\*.*?\*/)|(?:--|#)[^\n]*`)
+	fingerprintNumbers  = regexp.MustCompile(`\b-?(?:0x[0-9a-f]+|[0-9]+(?:\.[0-9]+)?(?:e[+-]?[0-9]+)?)\b`)
+	fingerprintLists    = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	fingerprintValues   = regexp.MustCompile(`(values\s*\(\?\+\))(?:\s*,\s*\(\?\+\))+`)
+	fingerprintSpaces   = regexp.MustCompile(`\s+`)
+)
+
+type MySQLSlowlog struct {
+	config MySQLSlowlogConfig
+	log    Logger
+}
+
+// MySQLSlowlogConfig reads the queries appended to the slow query log since the previous run,
+// including the rest of a rotated log that is still next to Path (e.g. Path.1).
+// Queries are grouped by fingerprint and named by its hash, e.g. mysql_slowlog.query.<hash>.query_time.sum,
+// only the Top (10 by default) fingerprints by total query time are reported.
+type MySQLSlowlogConfig struct {
+	Path          string `toml:"path"`
+	StatePath     string `toml:"state_path"`
+	ReadFromStart bool   `toml:"read_from_start"`
+	Prefix        string `toml:"prefix"`
+	Top           int    `toml:"top"`
+	TimestampConfig
+}
+
+type slowlogEntry struct {
+	stats map[string]float64
+	query []string
+}
+
+type slowlogFingerprint struct {
+	query string
+	count float64
+	sum   map[string]float64
+	max   map[string]float64
+}
+
+func NewMySQLSlowlog(slConfig MySQLSlowlogConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var sl *MySQLSlowlog
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return sl, err
+	}
+
+	var config MySQLSlowlogConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return sl, err
+	}
+
+	if slConfig.Path != "" {
+		config.Path = slConfig.Path
+	}
+
+	if config.Path == "" {
+		return sl, errors.New("path is required")
+	}
+
+	if slConfig.StatePath != "" {
+		config.StatePath = slConfig.StatePath
+	} else if config.StatePath == "" {
+		config.StatePath = DefaultStatePath("mysql_slowlog")
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = "mysql_slowlog"
+	}
+
+	if config.Top == 0 {
+		config.Top = 10
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return sl, err
+	}
+
+	sl = &MySQLSlowlog{
+		config: config,
+		log:    log,
+	}
+
+	return sl, err
+}
+
+// Fingerprint normalizes a query by stripping comments and literals,
+// e.g. "SELECT * FROM t WHERE id IN (1, 2)" becomes "select * from t where id in(?+)".
+func Fingerprint(query string) string {
+	q := strings.ToLower(strings.TrimSpace(query))
+	q = fingerprintLiterals.ReplaceAllStringFunc(q, func(s string) string {
+		if s[0] == '\'' || s[0] == '"' {
+			return "?"
+		}
+		return ""
+	})
+	q = fingerprintNumbers.ReplaceAllString(q, "?")
+	q = fingerprintLists.ReplaceAllString(q, "(?+)")
+	q = fingerprintValues.ReplaceAllString(q, "$1")
+	q = fingerprintSpaces.ReplaceAllString(q, " ")
+	q = strings.Replace(q, " (?+)", "(?+)", -1)
+
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(q), ";"))
+}
+
+func fingerprintHash(fingerprint string) string {
+	h := fnv.New64a()
+	h.Write([]byte(fingerprint))
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// parseSlowlogStats parses "# Query_time: 1.000  Lock_time: 0.000 Rows_sent: 1  Rows_examined: 0".
+func parseSlowlogStats(line string) map[string]float64 {
+	stats := make(map[string]float64)
+	fields := strings.Fields(strings.TrimPrefix(line, "#"))
+	for i := 0; i+1 < len(fields); i += 2 {
+		if !strings.HasSuffix(fields[i], ":") {
+			i--
+			continue
+		}
+
+		v, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			continue
+		}
+		stats[strings.ToLower(strings.TrimSuffix(fields[i], ":"))] = v
+	}
+
+	return stats
+}
+
+// slowlogParser splits the slow query log into entries, an entry starts with its "# ..." header lines.
+// An entry is complete when the next one starts or its query ends with ";", the last entry
+// is kept for the next run if it was not completely written when the log was read.
+type slowlogParser struct {
+	entry slowlogEntry
+	start int64
+	begun bool
+	add   func(slowlogEntry)
+}
+
+func (p *slowlogParser) line(line string, offset int64) {
+	if strings.HasPrefix(line, "# ") {
+		if len(p.entry.query) > 0 {
+			p.flush()
+		}
+
+		if !p.begun {
+			p.start = offset
+			p.begun = true
+		}
+
+		if strings.HasPrefix(line, "# Query_time:") {
+			p.entry.stats = parseSlowlogStats(line)
+		}
+		return
+	}
+
+	// server start banners and lines of a partially read entry
+	if p.entry.stats == nil {
+		return
+	}
+
+	lower := strings.ToLower(line)
+	if len(p.entry.query) == 0 && (strings.HasPrefix(lower, "set timestamp=") || strings.HasPrefix(lower, "use ")) {
+		return
+	}
+
+	p.entry.query = append(p.entry.query, line)
+}
+
+func (p *slowlogParser) flush() {
+	if p.entry.stats != nil && len(p.entry.query) > 0 {
+		p.add(p.entry)
+	}
+	p.entry = slowlogEntry{}
+	p.begun = false
+}
+
+// done flushes the last entry if it is complete and returns the position to continue from.
+// The last entry of a rotated log is complete, or it is never completed.
+func (p *slowlogParser) done(end int64, rotated bool) int64 {
+	n := len(p.entry.query)
+	if rotated || (n > 0 && strings.HasSuffix(strings.TrimSpace(p.entry.query[n-1]), ";")) {
+		p.flush()
+	}
+
+	if p.begun {
+		return p.start
+	}
+
+	return end
+}
+
+func (sl *MySQLSlowlog) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	state, err := NewState(sl.config.StatePath)
+	if err != nil {
+		return metrics, err
+	}
+	defer state.Close()
+
+	fingerprints := make(map[string]*slowlogFingerprint)
+	total := &slowlogFingerprint{
+		sum: make(map[string]float64),
+		max: make(map[string]float64),
+	}
+
+	parser := &slowlogParser{
+		add: func(e slowlogEntry) {
+			fp := Fingerprint(strings.Join(e.query, "\n"))
+			hash := fingerprintHash(fp)
+
+			f, ok := fingerprints[hash]
+			if !ok {
+				f = &slowlogFingerprint{
+					query: fp,
+					sum:   make(map[string]float64),
+					max:   make(map[string]float64),
+				}
+				fingerprints[hash] = f
+			}
+
+			for _, s := range []*slowlogFingerprint{f, total} {
+				s.count++
+				for _, k := range slowlogStats {
+					s.sum[k] += e.stats[k]
+					if e.stats[k] > s.max[k] {
+						s.max[k] = e.stats[k]
+					}
+				}
+			}
+		},
+	}
+
+	err = TailFileEntries(&state, sl.config.Path, sl.config.ReadFromStart, parser.line, parser.done)
+	metrics = append(metrics, SectionMetrics(sl.config.Prefix, err, now)...)
+	if err != nil {
+		return sl.config.Apply(metrics, now), err
+	}
+
+	hashes := make([]string, 0, len(fingerprints))
+	for h := range fingerprints {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		a, b := fingerprints[hashes[i]], fingerprints[hashes[j]]
+		if a.sum["query_time"] != b.sum["query_time"] {
+			return a.sum["query_time"] > b.sum["query_time"]
+		}
+		return hashes[i] < hashes[j]
+	})
+
+	if sl.config.Top > 0 && len(hashes) > sl.config.Top {
+		hashes = hashes[:sl.config.Top]
+	}
+
+	stats := make(map[string]float64)
+	add := func(name string, f *slowlogFingerprint) {
+		stats[fmt.Sprintf("%s.count", name)] = f.count
+		for _, k := range slowlogStats {
+			stats[fmt.Sprintf("%s.%s.sum", name, k)] = f.sum[k]
+			stats[fmt.Sprintf("%s.%s.max", name, k)] = f.max[k]
+			if f.count > 0 {
+				stats[fmt.Sprintf("%s.%s.avg", name, k)] = f.sum[k] / f.count
+			}
+		}
+	}
+
+	add("total", total)
+	for _, h := range hashes {
+		sl.log.Debug(fmt.Sprintf("%s: %s", h, fingerprints[h].query))
+		add(fmt.Sprintf("query.%s", h), fingerprints[h])
+	}
+
+	for _, key := range sortedKeys(stats) {
+		metrics = append(metrics, Metric{
+			Name:  fmt.Sprintf("%s.%s", sl.config.Prefix, key),
+			Value: stats[key],
+			Time:  now,
+		})
+	}
+
+	return sl.config.Apply(metrics, now), err
+}
+
+func (sl *MySQLSlowlog) Teardown() {
+
+}
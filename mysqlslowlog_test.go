@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "SELECT * FROM t WHERE id IN (1, 2, 3)",
+			want:  "select * from t where id in(?+)",
+		},
+		{
+			query: "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y');",
+			want:  "insert into t (a, b) values(?+)",
+		},
+		{
+			query: "SELECT  name\nFROM users /* comment */ WHERE email = 'a@example.com' -- trailing",
+			want:  "select name from users where email = ?",
+		},
+		{
+			query: "SELECT * FROM t WHERE a = 'x # y' AND b = \"--\" AND c = '/* z */'",
+			want:  "select * from t where a = ? and b = ? and c = ?",
+		},
+		{
+			query: "SELECT * FROM t WHERE a = 'it''s' AND b = 'don\\'t'",
+			want:  "select * from t where a = ? and b = ?",
+		},
+		{
+			query: "/* don't */ SELECT 1",
+			want:  "select ?",
+		},
+		{
+			query: "SELECT * FROM t2 WHERE x = 0x1f AND y = 1.5e3",
+			want:  "select * from t2 where x = ? and y = ?",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Fingerprint(tt.query); got != tt.want {
+			t.Errorf("Fingerprint(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	a := Fingerprint("SELECT * FROM a WHERE x = '#1'")
+	b := Fingerprint("SELECT * FROM a WHERE x = '#2' AND y = 1")
+	if a == b {
+		t.Errorf("different statements share the fingerprint %q", a)
+	}
+}
+
+const slowlogEntries = `/usr/sbin/mysqld, Version: 5.7.22-log (MySQL Community Server (GPL)). started with:
+Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+Time                 Id Command    Argument
+# Time: 2018-06-01T00:00:01.000000Z
+# User@Host: app[app] @ localhost []  Id:     1
+# Query_time: 2.000000  Lock_time: 0.100000 Rows_sent: 1  Rows_examined: 100
+use app;
+SET timestamp=1527811201;
+SELECT * FROM users WHERE id = 1;
+# Time: 2018-06-01T00:00:02.000000Z
+# User@Host: app[app] @ localhost []  Id:     1
+# Query_time: 1.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 50
+SET timestamp=1527811202;
+SELECT * FROM users
+WHERE id = 2;
+`
+
+const slowlogPartial = `# Time: 2018-06-01T00:00:03.000000Z
+# User@Host: app[app] @ localhost []  Id:     1
+# Query_time: 4.000000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 10
+SET timestamp=1527811203;
+UPDATE users SET name = 'a'
+`
+
+const slowlogRest = `WHERE id = 3;
+`
+
+func TestMySQLSlowlogFetchMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqlslowlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logPath := filepath.Join(dir, "slow.log")
+	conf := filepath.Join(dir, "slowlog.toml")
+	err = ioutil.WriteFile(conf, []byte(fmt.Sprintf("path = %q\nstate_path = %q\nread_from_start = true\n",
+		logPath, filepath.Join(dir, "state.db"))), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewMySQLSlowlog(MySQLSlowlogConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendLog := func(s string) {
+		f, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	fetch := func(rotated, appended string) map[string]float64 {
+		if rotated != "" {
+			appendLog(rotated)
+			if err := os.Rename(logPath, logPath+".1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		appendLog(appended)
+
+		metrics, err := in.FetchMetrics()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := make(map[string]float64)
+		for _, m := range metrics {
+			stats[m.Name] = m.Value.(float64)
+		}
+
+		return stats
+	}
+
+	usersHash := fingerprintHash("select * from users where id = ?")
+	updateHash := fingerprintHash("update users set name = ? where id = ?")
+	partialHash := fingerprintHash("update users set name = ?")
+
+	tests := []struct {
+		name     string
+		rotated  string
+		appended string
+		want     map[string]float64
+	}{
+		{
+			name:     "the incomplete last entry is left for the next run",
+			appended: slowlogEntries + slowlogPartial,
+			want: map[string]float64{
+				"mysql_slowlog.up":                                    1,
+				"mysql_slowlog.total.count":                           2,
+				"mysql_slowlog.total.query_time.sum":                  3,
+				"mysql_slowlog.total.rows_examined.max":               100,
+				"mysql_slowlog.query." + usersHash + ".count":         2,
+				"mysql_slowlog.query." + usersHash + ".lock_time.sum": 0.1,
+			},
+		},
+		{
+			name:     "the completed entry is read from its start",
+			appended: slowlogRest,
+			want: map[string]float64{
+				"mysql_slowlog.total.count":                                1,
+				"mysql_slowlog.total.query_time.sum":                       4,
+				"mysql_slowlog.query." + updateHash + ".count":             1,
+				"mysql_slowlog.query." + updateHash + ".rows_examined.avg": 10,
+			},
+		},
+		{
+			name: "nothing new",
+			want: map[string]float64{
+				"mysql_slowlog.total.count": 0,
+			},
+		},
+		{
+			// e.g. "administrator command: Quit" has no ";"
+			name:    "the last entry of a rotated log is complete",
+			rotated: slowlogPartial,
+			want: map[string]float64{
+				"mysql_slowlog.total.count":                     1,
+				"mysql_slowlog.total.query_time.sum":            4,
+				"mysql_slowlog.query." + partialHash + ".count": 1,
+			},
+		},
+		{
+			name:     "the new log is read from its start",
+			appended: slowlogPartial + slowlogRest,
+			want: map[string]float64{
+				"mysql_slowlog.total.count":                    1,
+				"mysql_slowlog.query." + updateHash + ".count": 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.rotated != "" && runtime.GOOS != "linux" {
+			// rotated files are detected by inode on linux only
+			continue
+		}
+
+		stats := fetch(tt.rotated, tt.appended)
+		for name, want := range tt.want {
+			got, ok := stats[name]
+			if !ok || got != want {
+				t.Errorf("%s: %s = %v (found %v), want %v", tt.name, name, got, ok, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	_ "github.com/lib/pq"
+	"strconv"
+	"strings"
+)
+
+type PostgreSQL struct {
+	db     *sql.DB
+	config PostgreSQLConfig
+	log    Logger
+}
+
+type PostgreSQLConfig struct {
+	Host        string                               `toml:"host"`
+	User        string                               `toml:"user"`
+	Port        int                                  `toml:"port"`
+	Password    string                               `toml:"password"`
+	Database    string                               `toml:"database"`
+	SSLMode     string                               `toml:"sslmode"`
+	Timeout     int                                  `toml:"timeout"`
+	Concurrency int                                  `toml:"concurrency"`
+	Metrics     map[string][]PostgreSQLMetricsConfig `toml:"metrics"`
+	TimestampConfig
+}
+
+type PostgreSQLMetricsConfig struct {
+	MetricsConfig
+}
+
+func NewPostgreSQL(pgConfig PostgreSQLConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var pg *PostgreSQL
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return pg, err
+	}
+
+	var config PostgreSQLConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return pg, err
+	}
+
+	if pgConfig.Host != "" {
+		config.Host = pgConfig.Host
+	} else if config.Host == "" {
+		config.Host = "localhost"
+	}
+
+	if pgConfig.User != "" {
+		config.User = pgConfig.User
+	}
+
+	if pgConfig.Port != 0 {
+		config.Port = pgConfig.Port
+	} else if config.Port == 0 {
+		config.Port = 5432
+	}
+
+	if pgConfig.Password != "" {
+		config.Password = pgConfig.Password
+	}
+
+	if pgConfig.Timeout > 0 {
+		config.Timeout = pgConfig.Timeout
+	}
+
+	if pgConfig.Concurrency > 0 {
+		config.Concurrency = pgConfig.Concurrency
+	}
+
+	if config.Database == "" {
+		config.Database = "postgres"
+	}
+
+	if config.SSLMode == "" {
+		config.SSLMode = "disable"
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return pg, err
+	}
+
+	params := []string{
+		fmt.Sprintf("host=%s", pqQuote(config.Host)),
+		fmt.Sprintf("port=%d", config.Port),
+		fmt.Sprintf("dbname=%s", pqQuote(config.Database)),
+		fmt.Sprintf("sslmode=%s", pqQuote(config.SSLMode)),
+		fmt.Sprintf("connect_timeout=%d", config.Timeout),
+	}
+
+	if config.User != "" {
+		params = append(params, fmt.Sprintf("user=%s", pqQuote(config.User)))
+	}
+
+	if config.Password != "" {
+		params = append(params, fmt.Sprintf("password=%s", pqQuote(config.Password)))
+	}
+
+	var db *sql.DB
+	db, err = sql.Open("postgres", strings.Join(params, " "))
+
+	pg = &PostgreSQL{
+		db:     db,
+		config: config,
+		log:    log,
+	}
+
+	return pg, err
+}
+
+func pqQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+
+	return fmt.Sprintf("'%s'", s)
+}
+
+// queryRows reads every row into a map of column name to value.
+// NULL columns are left out.
+func queryRows(db *sql.DB, query string, args ...interface{}) ([]map[string]string, error) {
+	var err error
+	var data []map[string]string
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return data, err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	data = make([]map[string]string, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return data, err
+		}
+
+		row := make(map[string]string)
+		for i, val := range values {
+			if val.Valid {
+				row[columns[i]] = val.String
+			}
+		}
+		data = append(data, row)
+	}
+
+	return data, rows.Err()
+}
+
+func (p *PostgreSQL) serverVersion() (int, error) {
+	var version string
+
+	err := p.db.QueryRow("SHOW server_version_num").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(version)
+}
+
+func (p *PostgreSQL) statDatabase() (map[string]float64, error) {
+	var err error
+	var stats map[string]float64
+
+	rows, err := queryRows(p.db, "SELECT * FROM pg_stat_database WHERE datname IS NOT NULL")
+	if err != nil {
+		return stats, err
+	}
+
+	return statDatabaseStats(rows), nil
+}
+
+// statDatabaseStats names the numeric columns of pg_stat_database <datname>.<column>,
+// <column> is the sum over all databases.
+func statDatabaseStats(rows []map[string]string) map[string]float64 {
+	stats := make(map[string]float64)
+	for _, row := range rows {
+		datname := sanitizeName(row["datname"])
+		for column, value := range row {
+			fval, err := strconv.ParseFloat(value, 64)
+			if err != nil || column == "datid" {
+				continue
+			}
+
+			stats[fmt.Sprintf("%s.%s", datname, column)] = fval
+			stats[column] += fval
+		}
+	}
+
+	return stats
+}
+
+func (p *PostgreSQL) statBgwriter() (map[string]float64, error) {
+	var err error
+	var stats map[string]float64
+
+	rows, err := queryRows(p.db, "SELECT * FROM pg_stat_bgwriter")
+	if err != nil {
+		return stats, err
+	}
+
+	stats = make(map[string]float64)
+	for _, row := range rows {
+		for column, value := range row {
+			fval, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			stats[column] = fval
+		}
+	}
+
+	return stats, err
+}
+
+func (p *PostgreSQL) replication() (map[string]float64, error) {
+	var err error
+	var stats map[string]float64
+
+	version, err := p.serverVersion()
+	if err != nil {
+		return stats, err
+	}
+
+	var recovery bool
+	err = p.db.QueryRow("SELECT pg_is_in_recovery()").Scan(&recovery)
+	if err != nil {
+		return stats, err
+	}
+
+	stats = make(map[string]float64)
+
+	if recovery {
+		stats["is_in_recovery"] = 1
+
+		var lag sql.NullFloat64
+		err = p.db.QueryRow("SELECT EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())").Scan(&lag)
+		if err != nil {
+			return stats, err
+		}
+
+		if lag.Valid {
+			stats["standby_replay_lag_seconds"] = lag.Float64
+		}
+
+		return stats, err
+	}
+
+	stats["is_in_recovery"] = 0
+
+	// the xlog functions were renamed and replay_lag was added in 10
+	query := `SELECT application_name, client_addr,
+		pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn) AS replay_lag_bytes,
+		EXTRACT(EPOCH FROM replay_lag) AS replay_lag_seconds
+		FROM pg_stat_replication`
+	if version < 100000 {
+		query = `SELECT application_name, client_addr,
+			pg_xlog_location_diff(pg_current_xlog_location(), replay_location) AS replay_lag_bytes
+			FROM pg_stat_replication`
+	}
+
+	rows, err := queryRows(p.db, query)
+	if err != nil {
+		return stats, err
+	}
+
+	for k, v := range replicaStats(rows) {
+		stats[k] = v
+	}
+
+	return stats, nil
+}
+
+// replicaStats reports the lag of every row of pg_stat_replication as <application_name>.<column>
+// (the client address without a name), <column> is the lag of the furthest behind replica.
+func replicaStats(rows []map[string]string) map[string]float64 {
+	stats := make(map[string]float64)
+	stats["replicas"] = float64(len(rows))
+	for _, row := range rows {
+		name := row["application_name"]
+		if name == "" {
+			name = row["client_addr"]
+		}
+		name = sanitizeName(name)
+
+		for _, column := range []string{"replay_lag_bytes", "replay_lag_seconds"} {
+			fval, err := strconv.ParseFloat(row[column], 64)
+			if err != nil {
+				continue
+			}
+
+			stats[fmt.Sprintf("%s.%s", name, column)] = fval
+			if _, ok := stats[column]; !ok || fval > stats[column] {
+				stats[column] = fval
+			}
+		}
+	}
+
+	return stats
+}
+
+func (p *PostgreSQL) locks() (map[string]float64, error) {
+	var err error
+	var stats map[string]float64
+
+	rows, err := queryRows(p.db, "SELECT mode, granted, count(*) AS count FROM pg_locks GROUP BY mode, granted")
+	if err != nil {
+		return stats, err
+	}
+
+	return lockStats(rows), nil
+}
+
+// lockStats counts the locks per mode, <mode>_waiting and waiting count the ones not granted yet.
+func lockStats(rows []map[string]string) map[string]float64 {
+	stats := make(map[string]float64)
+	for _, row := range rows {
+		count, err := strconv.ParseFloat(row["count"], 64)
+		if err != nil {
+			continue
+		}
+
+		stats[row["mode"]] += count
+		stats["total"] += count
+
+		if granted, _ := strconv.ParseBool(row["granted"]); !granted {
+			stats[fmt.Sprintf("%s_waiting", row["mode"])] += count
+			stats["waiting"] += count
+		}
+	}
+
+	return stats
+}
+
+func (p *PostgreSQL) FetchMetrics() ([]Metric, error) {
+	var err error
+
+	now := ScheduledNow()
+
+	sections := make([]Section, 0, 4)
+	for _, s := range []Section{
+		{Name: "stat_database", Fetch: p.statDatabase},
+		{Name: "stat_bgwriter", Fetch: p.statBgwriter},
+		{Name: "replication", Fetch: p.replication},
+		{Name: "locks", Fetch: p.locks},
+	} {
+		if len(p.config.Metrics[s.Name]) > 0 {
+			sections = append(sections, s)
+		}
+	}
+
+	stats, errs, err := FetchSections(p.config.Concurrency, sections)
+
+	metrics := make([]Metric, 0)
+	for i, s := range sections {
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("postgresql.%s", s.Name), errs[i], now)...)
+		if errs[i] != nil {
+			continue
+		}
+
+		for _, c := range p.config.Metrics[s.Name] {
+			metrics = append(metrics, c.Metrics(stats[i], now)...)
+		}
+	}
+
+	return p.config.Apply(metrics, now), err
+}
+
+func (p *PostgreSQL) Teardown() {
+	_ = p.db.Close()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestStatDatabaseStats(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []map[string]string
+		want map[string]float64
+	}{
+		{
+			name: "databases are summed",
+			rows: []map[string]string{
+				{"datid": "1", "datname": "postgres", "numbackends": "1", "xact_commit": "100", "stats_reset": "2018-06-01 00:00:00+00"},
+				{"datid": "16384", "datname": "app", "numbackends": "4", "xact_commit": "900"},
+			},
+			want: map[string]float64{
+				"postgres.numbackends": 1,
+				"postgres.xact_commit": 100,
+				"app.numbackends":      4,
+				"app.xact_commit":      900,
+				"numbackends":          5,
+				"xact_commit":          1000,
+			},
+		},
+		{
+			name: "database names are sanitized",
+			rows: []map[string]string{
+				{"datname": "app.v2-staging", "numbackends": "2"},
+			},
+			want: map[string]float64{
+				"app_v2-staging.numbackends": 2,
+				"numbackends":                2,
+			},
+		},
+		{
+			name: "no databases",
+			want: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statDatabaseStats(tt.rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaStats(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []map[string]string
+		want map[string]float64
+	}{
+		{
+			name: "the furthest behind replica is the lag",
+			rows: []map[string]string{
+				{"application_name": "standby1", "client_addr": "10.0.0.2", "replay_lag_bytes": "1024", "replay_lag_seconds": "0.5"},
+				{"application_name": "standby2", "client_addr": "10.0.0.3", "replay_lag_bytes": "4096", "replay_lag_seconds": "0.25"},
+			},
+			want: map[string]float64{
+				"replicas":                    2,
+				"standby1.replay_lag_bytes":   1024,
+				"standby1.replay_lag_seconds": 0.5,
+				"standby2.replay_lag_bytes":   4096,
+				"standby2.replay_lag_seconds": 0.25,
+				"replay_lag_bytes":            4096,
+				"replay_lag_seconds":          0.5,
+			},
+		},
+		{
+			// replay_lag is NULL for an idle replica, older servers have no replay_lag_seconds
+			name: "unnamed replica without a time lag",
+			rows: []map[string]string{
+				{"client_addr": "10.0.0.2", "replay_lag_bytes": "0"},
+			},
+			want: map[string]float64{
+				"replicas":                  1,
+				"10_0_0_2.replay_lag_bytes": 0,
+				"replay_lag_bytes":          0,
+			},
+		},
+		{
+			name: "no replicas",
+			want: map[string]float64{"replicas": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replicaStats(tt.rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockStats(t *testing.T) {
+	rows := []map[string]string{
+		{"mode": "AccessShareLock", "granted": "true", "count": "5"},
+		{"mode": "RowExclusiveLock", "granted": "true", "count": "2"},
+		{"mode": "RowExclusiveLock", "granted": "false", "count": "1"},
+	}
+
+	want := map[string]float64{
+		"AccessShareLock":          5,
+		"RowExclusiveLock":         3,
+		"RowExclusiveLock_waiting": 1,
+		"total":                    8,
+		"waiting":                  1,
+	}
+
+	if got := lockStats(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestPostgreSQLFetchMetrics runs against the server in POSTGRESQL_TEST_DSN,
+// e.g. "host=localhost user=postgres sslmode=disable".
+func TestPostgreSQLFetchMetrics(t *testing.T) {
+	dsn := os.Getenv("POSTGRESQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRESQL_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := make(map[string][]PostgreSQLMetricsConfig)
+	for _, s := range []string{"stat_database", "stat_bgwriter", "replication", "locks"} {
+		metrics[s] = []PostgreSQLMetricsConfig{{MetricsConfig{Name: "*", Prefix: "postgresql." + s}}}
+	}
+
+	pg := &PostgreSQL{
+		db:     db,
+		config: PostgreSQLConfig{Metrics: metrics},
+		log:    NewLogger(),
+	}
+	defer pg.Teardown()
+
+	ms, err := pg.FetchMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := make(map[string]float64)
+	for _, m := range ms {
+		stats[m.Name] = m.Value.(float64)
+	}
+
+	for _, name := range []string{
+		"postgresql.stat_database.up",
+		"postgresql.stat_bgwriter.up",
+		"postgresql.replication.up",
+		"postgresql.locks.up",
+	} {
+		if stats[name] != 1 {
+			t.Errorf("%s = %v, want 1", name, stats[name])
+		}
+	}
+
+	for _, name := range []string{"postgresql.stat_database.numbackends", "postgresql.replication.is_in_recovery", "postgresql.locks.total"} {
+		if _, ok := stats[name]; !ok {
+			t.Errorf("%s not found", name)
+		}
+	}
+}
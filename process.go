@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Process struct {
+	config ProcessConfig
+	log    Logger
+}
+
+type ProcessConfig struct {
+	ProcRoot   string                 `toml:"proc_root"`
+	StatePath  string                 `toml:"state_path"`
+	ClockTicks float64                `toml:"clock_ticks"`
+	Processes  []ProcessMetricsConfig `toml:"processes"`
+	TimestampConfig
+}
+
+// ProcessMetricsConfig matches processes by Name (the command name in /proc/<pid>/comm),
+// a Cmdline regular expression and/or a Pidfile; every given condition has to match.
+// Metrics are named <prefix>.<alias>.<stat>, the prefix defaults to "process" and the alias to Name.
+type ProcessMetricsConfig struct {
+	Cmdline string `toml:"cmdline"`
+	Pidfile string `toml:"pidfile"`
+	MetricsConfig
+	cmdline *regexp.Regexp
+}
+
+type procStat struct {
+	comm      string
+	cmdline   string
+	ticks     float64
+	startTime float64
+	rss       float64
+	threads   float64
+	fds       float64
+	fdsOK     bool
+}
+
+type procCPU struct {
+	Ticks float64 `json:"ticks"`
+	Time  float64 `json:"time"`
+}
+
+func NewProcess(procConfig ProcessConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var p *Process
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return p, err
+	}
+
+	var config ProcessConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return p, err
+	}
+
+	if procConfig.ProcRoot != "" {
+		config.ProcRoot = procConfig.ProcRoot
+	} else if config.ProcRoot == "" {
+		config.ProcRoot = "/proc"
+	}
+
+	if procConfig.StatePath != "" {
+		config.StatePath = procConfig.StatePath
+	} else if config.StatePath == "" {
+		config.StatePath = DefaultStatePath("process")
+	}
+
+	if config.ClockTicks == 0 {
+		config.ClockTicks = 100
+	}
+
+	for i := range config.Processes {
+		pc := &config.Processes[i]
+
+		if pc.Name == "" && pc.Cmdline == "" && pc.Pidfile == "" {
+			return p, fmt.Errorf("processes[%d]: name, cmdline or pidfile is required", i)
+		}
+
+		if pc.Alias == "" {
+			pc.Alias = sanitizeName(pc.Name)
+		}
+
+		if pc.Alias == "" {
+			return p, fmt.Errorf("processes[%d]: alias is required", i)
+		}
+
+		if pc.Prefix == "" {
+			pc.Prefix = "process"
+		}
+
+		if pc.Cmdline != "" {
+			pc.cmdline, err = regexp.Compile(pc.Cmdline)
+			if err != nil {
+				return p, err
+			}
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return p, err
+	}
+
+	p = &Process{
+		config: config,
+		log:    log,
+	}
+
+	return p, err
+}
+
+func (p *Process) pidPath(pid int, name string) string {
+	return filepath.Join(p.config.ProcRoot, strconv.Itoa(pid), name)
+}
+
+func (p *Process) pids() ([]int, error) {
+	var pids []int
+
+	entries, err := ioutil.ReadDir(p.config.ProcRoot)
+	if err != nil {
+		return pids, err
+	}
+
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+func (p *Process) bootTime() (float64, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(p.config.ProcRoot, "stat"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, l := range strings.Split(string(buf), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) == 2 && fields[0] == "btime" {
+			return strconv.ParseFloat(fields[1], 64)
+		}
+	}
+
+	return 0, fmt.Errorf("btime not found in %s", filepath.Join(p.config.ProcRoot, "stat"))
+}
+
+// readStat reads /proc/<pid>/stat and, if cmdline is set, cmdline; enough to match the process.
+func (p *Process) readStat(pid int, cmdline bool) (procStat, error) {
+	var err error
+	var s procStat
+
+	buf, err := ioutil.ReadFile(p.pidPath(pid, "stat"))
+	if err != nil {
+		return s, err
+	}
+
+	// the command name may contain spaces and parentheses
+	stat := string(buf)
+	open := strings.Index(stat, "(")
+	closing := strings.LastIndex(stat, ")")
+	if open < 0 || closing < open {
+		return s, fmt.Errorf("Invalid stat: %s", stat)
+	}
+	s.comm = stat[open+1 : closing]
+
+	// fields after the command name start with state (field 3 in proc(5))
+	fields := strings.Fields(stat[closing+1:])
+	if len(fields) < 22 {
+		return s, fmt.Errorf("Invalid stat: %s", stat)
+	}
+
+	values := parseFloats(fields)
+	s.ticks = values[11] + values[12]
+	s.threads = values[17]
+	s.startTime = values[19]
+
+	if cmdline {
+		buf, err = ioutil.ReadFile(p.pidPath(pid, "cmdline"))
+		if err != nil {
+			return s, err
+		}
+		s.cmdline = strings.TrimSpace(strings.Replace(string(buf), "\x00", " ", -1))
+	}
+
+	return s, nil
+}
+
+// readStatus adds the memory, threads and open files of /proc/<pid>/status and fd to a matched process.
+func (p *Process) readStatus(pid int, s *procStat) error {
+	buf, err := ioutil.ReadFile(p.pidPath(pid, "status"))
+	if err != nil {
+		return err
+	}
+
+	for _, l := range strings.Split(string(buf), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "VmRSS:":
+			s.rss = parseFloats(fields[1:2])[0] * 1024
+		case "Threads:":
+			s.threads = parseFloats(fields[1:2])[0]
+		}
+	}
+
+	// reading fd of other users' processes needs privileges
+	fds, err := ioutil.ReadDir(p.pidPath(pid, "fd"))
+	if err == nil {
+		s.fds = float64(len(fds))
+		s.fdsOK = true
+	}
+
+	return nil
+}
+
+func readPidfile(path string) (int, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(buf)))
+}
+
+// candidate reports whether pid may match a config, processes that are not in the pidfile
+// of every config are skipped without reading them.
+func (p *Process) candidate(pid int, pidfiles []int) bool {
+	for i, pc := range p.config.Processes {
+		if pc.Pidfile == "" || pidfiles[i] == pid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (pc *ProcessMetricsConfig) match(pid, pidfile int, s procStat) bool {
+	if pc.Pidfile != "" && pid != pidfile {
+		return false
+	}
+
+	if pc.Name != "" && s.comm != pc.Name {
+		return false
+	}
+
+	if pc.cmdline != nil && !pc.cmdline.MatchString(s.cmdline) {
+		return false
+	}
+
+	return true
+}
+
+func (p *Process) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	// uptime and the CPU rate base use the wall clock, now is the scheduled time of the run
+	wall := time.Now()
+	metrics := make([]Metric, 0)
+
+	pids, err := p.pids()
+	if err != nil {
+		return metrics, err
+	}
+
+	btime, err := p.bootTime()
+	if err != nil {
+		return metrics, err
+	}
+
+	state, err := NewState(p.config.StatePath)
+	if err != nil {
+		return metrics, err
+	}
+	defer state.Close()
+
+	var cmdline bool
+	pidfiles := make([]int, len(p.config.Processes))
+	for i, pc := range p.config.Processes {
+		cmdline = cmdline || pc.cmdline != nil
+
+		pidfiles[i] = -1
+		if pc.Pidfile != "" {
+			pidfiles[i], err = readPidfile(pc.Pidfile)
+			if err != nil {
+				p.log.Debug(err)
+			}
+		}
+	}
+
+	// only the processes matched by a config are read completely
+	matches := make([][]int, len(p.config.Processes))
+	stats := make(map[int]procStat)
+	for _, pid := range pids {
+		if !p.candidate(pid, pidfiles) {
+			continue
+		}
+
+		s, err := p.readStat(pid, cmdline)
+		if err == nil {
+			var matched bool
+			for i := range p.config.Processes {
+				if p.config.Processes[i].match(pid, pidfiles[i], s) {
+					matches[i] = append(matches[i], pid)
+					matched = true
+				}
+			}
+
+			if !matched {
+				continue
+			}
+
+			err = p.readStatus(pid, &s)
+		}
+
+		if err != nil {
+			// the process has exited in the meantime
+			if !os.IsNotExist(err) {
+				p.log.Debug(err)
+			}
+			continue
+		}
+		stats[pid] = s
+	}
+
+	var errs MultiError
+	for i, pc := range p.config.Processes {
+		values := make(map[string]float64)
+		values["running"] = 0
+		values["count"] = 0
+
+		var ticks float64
+		for _, pid := range matches[i] {
+			s, ok := stats[pid]
+			if !ok {
+				continue
+			}
+
+			values["count"]++
+			ticks += s.ticks
+			values["rss"] += s.rss
+			values["threads"] += s.threads
+
+			if s.rss > values["rss_max"] {
+				values["rss_max"] = s.rss
+			}
+
+			if s.fdsOK {
+				values["fds"] += s.fds
+				if s.fds > values["fds_max"] {
+					values["fds_max"] = s.fds
+				}
+			}
+
+			uptime := float64(wall.Unix()) - (btime + s.startTime/p.config.ClockTicks)
+			if uptime > values["uptime"] {
+				values["uptime"] = uptime
+			}
+		}
+
+		if values["count"] > 0 {
+			values["running"] = 1
+
+			var prev procCPU
+			cur := procCPU{
+				Ticks: ticks,
+				Time:  float64(wall.UnixNano()) / float64(time.Second),
+			}
+
+			found, err := state.Get("process", pc.Alias, &prev)
+			if err != nil {
+				errs = append(errs, err)
+			}
+
+			// counters go back when processes have been restarted
+			if found && cur.Time > prev.Time && cur.Ticks >= prev.Ticks {
+				values["cpu"] = (cur.Ticks - prev.Ticks) / p.config.ClockTicks / (cur.Time - prev.Time) * 100
+			}
+
+			if err = state.Put("process", pc.Alias, cur); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		for _, key := range sortedKeys(values) {
+			metrics = append(metrics, Metric{
+				Name:  fmt.Sprintf("%s.%s.%s", pc.Prefix, pc.Alias, key),
+				Value: values[key],
+				Time:  now,
+			})
+		}
+	}
+
+	return p.config.Apply(metrics, now), errs.ErrorOrNil()
+}
+
+func (p *Process) Teardown() {
+
+}
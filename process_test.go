@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// procPidStat is /proc/<pid>/stat with utime 300, stime 100, 2 threads and a start time of 1000 ticks.
+func procPidStat(pid int, comm string) string {
+	return fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 4194560 100 0 0 0 300 100 0 0 20 0 2 0 1000 100000 500 18446744073709551615\n", pid, comm, pid, pid)
+}
+
+func TestProcessFetchMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	procRoot := filepath.Join(dir, "proc")
+	files := map[string]string{
+		"stat":           "cpu  1 2 3 4\nbtime 1527811200\n",
+		"100/stat":       procPidStat(100, "nginx"),
+		"100/cmdline":    "nginx: master process /usr/sbin/nginx\x00",
+		"100/status":     "Name:\tnginx\nVmRSS:\t    2048 kB\nThreads:\t1\n",
+		"101/stat":       procPidStat(101, "nginx"),
+		"101/cmdline":    "nginx: worker process\x00",
+		"101/status":     "Name:\tnginx\nVmRSS:\t    4096 kB\nThreads:\t1\n",
+		"200/stat":       procPidStat(200, "my server (2)"),
+		"200/cmdline":    "/usr/bin/my-server\x00--port\x008080\x00",
+		"200/status":     "Name:\tmy server (2)\nVmRSS:\t    1024 kB\nThreads:\t8\n",
+		"300/stat":       procPidStat(300, "sshd"),
+		"300/cmdline":    "/usr/sbin/sshd\x00-D\x00",
+		"run/redis.pid":  "400\n",
+		"400/stat":       procPidStat(400, "redis-server"),
+		"400/cmdline":    "/usr/bin/redis-server *:6379\x00",
+		"400/status":     "Name:\tredis-server\nVmRSS:\t    8192 kB\nThreads:\t4\n",
+		"not-a-pid/stat": "",
+	}
+	// 300 has no status and fd, only matched processes are read completely
+	writeProcFixture(t, procRoot, files)
+	for _, pid := range []string{"100", "101", "200", "400"} {
+		if err := os.MkdirAll(filepath.Join(procRoot, pid, "fd"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(procRoot, "100", "fd", "0"), nil, 0644)
+	ioutil.WriteFile(filepath.Join(procRoot, "100", "fd", "1"), nil, 0644)
+
+	conf := filepath.Join(dir, "process.toml")
+	err = ioutil.WriteFile(conf, []byte(fmt.Sprintf(`proc_root = %q
+state_path = %q
+
+[[processes]]
+name = "nginx"
+
+[[processes]]
+cmdline = "my-server .*--port 8080"
+alias = "my_server"
+
+[[processes]]
+pidfile = %q
+alias = "redis"
+
+[[processes]]
+name = "postgres"
+`, procRoot, filepath.Join(dir, "state.db"), filepath.Join(procRoot, "run", "redis.pid"))), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewProcess(ProcessConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := in.FetchMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := make(map[string]float64)
+	for _, m := range metrics {
+		stats[m.Name] = m.Value.(float64)
+	}
+
+	want := map[string]float64{
+		"process.nginx.running":    1,
+		"process.nginx.count":      2,
+		"process.nginx.rss":        6144 * 1024,
+		"process.nginx.rss_max":    4096 * 1024,
+		"process.nginx.threads":    2,
+		"process.nginx.fds":        2,
+		"process.nginx.fds_max":    2,
+		"process.my_server.count":  1,
+		"process.my_server.rss":    1024 * 1024,
+		"process.redis.count":      1,
+		"process.redis.threads":    4,
+		"process.postgres.count":   0,
+		"process.postgres.running": 0,
+	}
+
+	for name, v := range want {
+		if got, ok := stats[name]; !ok || got != v {
+			t.Errorf("%s = %v (found %v), want %v", name, got, ok, v)
+		}
+	}
+
+	if _, ok := stats["process.nginx.uptime"]; !ok {
+		t.Error("process.nginx.uptime not found")
+	}
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultNameTemplate = `{{.Name}}{{range .LabelValues}}.{{.}}{{end}}{{if .Quantile}}.{{.Quantile}}{{end}}`
+
+// defaultEndpointNameTemplate keeps the series of several urls apart.
+const defaultEndpointNameTemplate = `{{.Endpoint}}.` + defaultNameTemplate
+
+type Prometheus struct {
+	client *http.Client
+	config PrometheusConfig
+	log    Logger
+}
+
+type PrometheusConfig struct {
+	URLs         []string                  `toml:"urls"`
+	Headers      map[string]string         `toml:"headers"`
+	Timeout      int                       `toml:"timeout"`
+	Concurrency  int                       `toml:"concurrency"`
+	NameTemplate string                    `toml:"name_template"`
+	Metrics      []PrometheusMetricsConfig `toml:"metrics"`
+	TLSConfig
+	TimestampConfig
+}
+
+// PrometheusMetricsConfig selects series by metric name (or family name of histograms and summaries)
+// and label matchers, which are anchored regular expressions.
+// With several urls the default name template starts with the endpoint (host_port of the url),
+// custom templates should include {{.Endpoint}} to keep the series apart.
+// Quantiles are computed from the buckets of histograms, e.g. [0.5, 0.9, 0.99].
+type PrometheusMetricsConfig struct {
+	Labels       map[string]string `toml:"labels"`
+	NameTemplate string            `toml:"name_template"`
+	Quantiles    []float64         `toml:"quantiles"`
+	MetricsConfig
+	matchers map[string]*regexp.Regexp
+	tmpl     *template.Template
+}
+
+type PromSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	Time   time.Time
+}
+
+// PromFamilies maps metric family names to their type (counter, gauge, histogram, summary, untyped).
+type PromFamilies map[string]string
+
+// LabelNameData is passed to name templates.
+// LabelValues are the sanitized values sorted by label name,
+// le and quantile are replaced by Quantile (e.g. "p99") when it is set.
+// Endpoint is the scraped host of the prometheus input.
+type LabelNameData struct {
+	Endpoint    string
+	Name        string
+	Labels      map[string]string
+	LabelValues []string
+	Quantile    string
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+func sanitizeName(s string) string {
+	return strings.Trim(invalidNameChars.ReplaceAllString(s, "_"), "_")
+}
+
+func NewNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultNameTemplate
+	}
+
+	return template.New("name").Funcs(template.FuncMap{
+		"sanitize": sanitizeName,
+	}).Parse(text)
+}
+
+// LabelName flattens labels into a metric name using tmpl.
+func LabelName(tmpl *template.Template, name string, labels map[string]string, quantile string) (string, error) {
+	return endpointLabelName(tmpl, "", name, labels, quantile)
+}
+
+func endpointLabelName(tmpl *template.Template, endpoint, name string, labels map[string]string, quantile string) (string, error) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if quantile != "" && (k == "le" || k == "quantile") {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := sanitizeName(labels[k]); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, LabelNameData{
+		Endpoint:    endpoint,
+		Name:        name,
+		Labels:      labels,
+		LabelValues: values,
+		Quantile:    quantile,
+	})
+
+	return buf.String(), err
+}
+
+func quantileName(q float64) string {
+	return sanitizeName(fmt.Sprintf("p%g", q*100))
+}
+
+func NewPrometheus(promConfig PrometheusConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var p *Prometheus
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return p, err
+	}
+
+	var config PrometheusConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return p, err
+	}
+
+	if len(promConfig.URLs) > 0 {
+		config.URLs = promConfig.URLs
+	}
+
+	if promConfig.Timeout > 0 {
+		config.Timeout = promConfig.Timeout
+	} else if config.Timeout == 0 {
+		config.Timeout = 10
+	}
+
+	if promConfig.Concurrency > 0 {
+		config.Concurrency = promConfig.Concurrency
+	}
+
+	if len(config.URLs) == 0 {
+		return p, errors.New("urls is required")
+	}
+
+	for i := range config.Metrics {
+		m := &config.Metrics[i]
+
+		m.matchers = make(map[string]*regexp.Regexp)
+		for label, re := range m.Labels {
+			m.matchers[label], err = regexp.Compile(fmt.Sprintf("^(?:%s)$", re))
+			if err != nil {
+				return p, err
+			}
+		}
+
+		// Select runs for every url in parallel and must not modify the config
+		m.SplitName()
+
+		tmpl := m.NameTemplate
+		if tmpl == "" {
+			tmpl = config.NameTemplate
+		}
+
+		if tmpl == "" && len(config.URLs) > 1 {
+			tmpl = defaultEndpointNameTemplate
+		}
+
+		m.tmpl, err = NewNameTemplate(tmpl)
+		if err != nil {
+			return p, err
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return p, err
+	}
+
+	var client *http.Client
+	client, err = NewHTTPClient(config.TLSConfig, config.Timeout)
+
+	p = &Prometheus{
+		client: client,
+		config: config,
+		log:    log,
+	}
+
+	return p, err
+}
+
+// ParsePrometheusText parses the Prometheus text exposition format.
+func ParsePrometheusText(r io.Reader) ([]PromSample, PromFamilies, error) {
+	samples := make([]PromSample, 0)
+	families := make(PromFamilies)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				families[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		s, err := parsePromSample(line)
+		if err != nil {
+			return samples, families, err
+		}
+
+		samples = append(samples, s)
+	}
+
+	return samples, families, scanner.Err()
+}
+
+func parsePromSample(line string) (PromSample, error) {
+	var err error
+	s := PromSample{
+		Labels: make(map[string]string),
+	}
+
+	i := strings.IndexAny(line, "{ \t")
+	if i < 0 {
+		return s, fmt.Errorf("Invalid sample: %s", line)
+	}
+	s.Name = line[:i]
+	rest := line[i:]
+
+	if rest[0] == '{' {
+		rest, err = parsePromLabels(rest[1:], s.Labels)
+		if err != nil {
+			return s, fmt.Errorf("%s: %s", err, line)
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return s, fmt.Errorf("Invalid sample: %s", line)
+	}
+
+	s.Value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, err
+	}
+
+	if len(fields) > 1 {
+		var ms int64
+		ms, err = strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return s, err
+		}
+		s.Time = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	}
+
+	return s, nil
+}
+
+// parsePromLabels parses `name="value",...}` and returns the rest of the line.
+func parsePromLabels(s string, labels map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return s, errors.New("Unterminated labels")
+		}
+
+		if s[0] == '}' {
+			return s[1:], nil
+		}
+
+		eq := strings.Index(s, "=")
+		if eq < 0 || len(s) < eq+2 || s[eq+1] != '"' {
+			return s, errors.New("Invalid labels")
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+2:]
+
+		var value bytes.Buffer
+		closed := false
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+
+			if c == '"' {
+				s = s[i+1:]
+				closed = true
+				break
+			}
+
+			value.WriteByte(c)
+		}
+
+		if !closed {
+			return s, errors.New("Unterminated label value")
+		}
+
+		labels[name] = value.String()
+	}
+}
+
+// family returns the family a sample belongs to and its suffix (_bucket, _sum, _count).
+func (f PromFamilies) family(name string) (string, string) {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, suffix)
+		if t := f[base]; t == "histogram" || t == "summary" {
+			return base, suffix
+		}
+	}
+
+	return name, ""
+}
+
+type promBucket struct {
+	le    float64
+	count float64
+}
+
+// HistogramQuantile estimates the q-quantile from cumulative buckets
+// the same way as Prometheus' histogram_quantile().
+func HistogramQuantile(q float64, buckets []promBucket) float64 {
+	if len(buckets) == 0 {
+		return math.NaN()
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].le < buckets[j].le })
+
+	if !math.IsInf(buckets[len(buckets)-1].le, 1) || len(buckets) < 2 {
+		return math.NaN()
+	}
+
+	total := buckets[len(buckets)-1].count
+	if total == 0 {
+		return math.NaN()
+	}
+
+	rank := q * total
+	b := sort.Search(len(buckets)-1, func(i int) bool { return buckets[i].count >= rank })
+
+	if b == len(buckets)-1 {
+		return buckets[len(buckets)-2].le
+	}
+
+	if b == 0 && buckets[0].le <= 0 {
+		return buckets[0].le
+	}
+
+	var start, count float64
+	end := buckets[b].le
+	if b > 0 {
+		start = buckets[b-1].le
+		count = buckets[b-1].count
+	}
+
+	bucketCount := buckets[b].count - count
+	if bucketCount == 0 {
+		return end
+	}
+
+	return start + (end-start)*((rank-count)/bucketCount)
+}
+
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "le" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m *PrometheusMetricsConfig) match(labels map[string]string) bool {
+	for label, re := range m.matchers {
+		if !re.MatchString(labels[label]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *PrometheusMetricsConfig) metric(endpoint, name string, s PromSample, quantile string, now time.Time) (Metric, error) {
+	name, err := endpointLabelName(m.tmpl, endpoint, name, s.Labels, quantile)
+
+	t := s.Time
+	if t.IsZero() {
+		t = now
+	}
+
+	return Metric{
+		Name:  name,
+		Value: m.CalcValue(s.Value),
+		Time:  t,
+	}, err
+}
+
+// Select converts the samples of endpoint matching the config into metrics.
+func (m *PrometheusMetricsConfig) Select(endpoint string, samples []PromSample, families PromFamilies, now time.Time) ([]Metric, error) {
+	var errs MultiError
+	metrics := make([]Metric, 0)
+
+	for _, n := range m.names {
+		quantiles := len(m.Quantiles) > 0 && families[n] == "histogram"
+
+		buckets := make(map[string][]promBucket)
+		bucketSamples := make(map[string]PromSample)
+		keys := make([]string, 0)
+
+		for _, s := range samples {
+			base, suffix := families.family(s.Name)
+			if (s.Name != n && base != n) || !m.match(s.Labels) {
+				continue
+			}
+
+			if quantiles && suffix == "_bucket" {
+				le, err := strconv.ParseFloat(s.Labels["le"], 64)
+				if err != nil {
+					continue
+				}
+
+				key := labelsKey(s.Labels)
+				if _, ok := buckets[key]; !ok {
+					keys = append(keys, key)
+					bucketSamples[key] = s
+				}
+				buckets[key] = append(buckets[key], promBucket{le: le, count: s.Value})
+				continue
+			}
+
+			name := m.CreateName(n)
+			if s.Name != n {
+				name = m.CreateName(n) + suffix
+			}
+
+			var quantile string
+			if q, ok := s.Labels["quantile"]; ok {
+				if fval, err := strconv.ParseFloat(q, 64); err == nil {
+					quantile = quantileName(fval)
+				}
+			}
+
+			metric, err := m.metric(endpoint, name, s, quantile, now)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			metrics = append(metrics, metric)
+		}
+
+		for _, key := range keys {
+			for _, q := range m.Quantiles {
+				s := bucketSamples[key]
+				s.Value = HistogramQuantile(q, buckets[key])
+				if math.IsNaN(s.Value) {
+					continue
+				}
+
+				metric, err := m.metric(endpoint, m.CreateName(n), s, quantileName(q), now)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				metrics = append(metrics, metric)
+			}
+		}
+	}
+
+	return metrics, errs.ErrorOrNil()
+}
+
+func (p *Prometheus) scrape(u string) ([]PromSample, PromFamilies, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+
+	return ParsePrometheusText(resp.Body)
+}
+
+func endpointName(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return sanitizeName(u)
+	}
+
+	return sanitizeName(parsed.Host)
+}
+
+func (p *Prometheus) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+
+	results := make([][]Metric, len(p.config.URLs))
+	errs := make([]error, len(p.config.URLs))
+
+	err := RunParallel(p.config.Concurrency, len(p.config.URLs), func(i int) error {
+		var samples []PromSample
+		var families PromFamilies
+		samples, families, errs[i] = p.scrape(p.config.URLs[i])
+		if errs[i] != nil {
+			return fmt.Errorf("%s: %s", p.config.URLs[i], errs[i])
+		}
+
+		var me MultiError
+		for j := range p.config.Metrics {
+			ms, err := p.config.Metrics[j].Select(endpointName(p.config.URLs[i]), samples, families, now)
+			if err != nil {
+				me = append(me, err)
+			}
+			results[i] = append(results[i], ms...)
+		}
+
+		return me.ErrorOrNil()
+	})
+
+	metrics := make([]Metric, 0)
+	for i, u := range p.config.URLs {
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("prometheus.%s", endpointName(u)), errs[i], now)...)
+		metrics = append(metrics, results[i]...)
+	}
+
+	return p.config.Apply(metrics, now), err
+}
+
+func (p *Prometheus) Teardown() {
+
+}
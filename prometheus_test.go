@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePrometheusText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		samples  []PromSample
+		families PromFamilies
+		wantErr  bool
+	}{
+		{
+			name: "counter with labels and timestamp",
+			text: `# HELP http_requests_total Requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 1027 1395066363000
+`,
+			samples: []PromSample{
+				{
+					Name:   "http_requests_total",
+					Labels: map[string]string{"method": "GET", "code": "200"},
+					Value:  1027,
+					Time:   time.Unix(1395066363, 0).UTC(),
+				},
+			},
+			families: PromFamilies{"http_requests_total": "counter"},
+		},
+		{
+			name: "escaped label values and special floats",
+			text: `msg{path="C:\\dir",text="say \"hi\"\n"} +Inf
+up NaN
+`,
+			samples: []PromSample{
+				{Name: "msg", Labels: map[string]string{"path": `C:\dir`, "text": "say \"hi\"\n"}, Value: math.Inf(1)},
+				{Name: "up", Labels: map[string]string{}, Value: math.NaN()},
+			},
+			families: PromFamilies{},
+		},
+		{
+			name:    "unterminated labels",
+			text:    `broken{a="b" 1`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			text:    `broken one`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, families, err := ParsePrometheusText(strings.NewReader(tt.text))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(families, tt.families) {
+				t.Errorf("families = %v, want %v", families, tt.families)
+			}
+
+			if len(samples) != len(tt.samples) {
+				t.Fatalf("got %d samples, want %d", len(samples), len(tt.samples))
+			}
+
+			for i, s := range samples {
+				want := tt.samples[i]
+				sameValue := s.Value == want.Value || (math.IsNaN(s.Value) && math.IsNaN(want.Value))
+				if s.Name != want.Name || !reflect.DeepEqual(s.Labels, want.Labels) || !sameValue || !s.Time.Equal(want.Time) {
+					t.Errorf("samples[%d] = %+v, want %+v", i, s, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	inf := math.Inf(1)
+
+	tests := []struct {
+		name    string
+		q       float64
+		buckets []promBucket
+		want    float64
+	}{
+		{
+			name:    "interpolates within a bucket",
+			q:       0.5,
+			buckets: []promBucket{{le: 0.1, count: 0}, {le: 0.2, count: 10}, {le: inf, count: 10}},
+			want:    0.15,
+		},
+		{
+			name:    "unsorted buckets",
+			q:       0.9,
+			buckets: []promBucket{{le: inf, count: 100}, {le: 1, count: 50}, {le: 2, count: 100}},
+			want:    1.8,
+		},
+		{
+			name:    "first bucket starts at zero",
+			q:       0.5,
+			buckets: []promBucket{{le: 1, count: 4}, {le: inf, count: 4}},
+			want:    0.5,
+		},
+		{
+			name:    "rank in the +Inf bucket returns the highest finite bound",
+			q:       0.99,
+			buckets: []promBucket{{le: 1, count: 5}, {le: inf, count: 10}},
+			want:    1,
+		},
+		{
+			name:    "no +Inf bucket",
+			q:       0.5,
+			buckets: []promBucket{{le: 1, count: 5}, {le: 2, count: 10}},
+			want:    math.NaN(),
+		},
+		{
+			name:    "no observations",
+			q:       0.5,
+			buckets: []promBucket{{le: 1, count: 0}, {le: inf, count: 0}},
+			want:    math.NaN(),
+		},
+		{
+			name: "no buckets",
+			q:    0.5,
+			want: math.NaN(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HistogramQuantile(tt.q, tt.buckets)
+			if math.IsNaN(tt.want) {
+				if !math.IsNaN(got) {
+					t.Errorf("got %v, want NaN", got)
+				}
+				return
+			}
+
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusFetchMetricsEndpoints(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# TYPE node_load1 gauge\nnode_load1 0.5\n")
+	})
+
+	s1 := httptest.NewServer(handler)
+	defer s1.Close()
+	s2 := httptest.NewServer(handler)
+	defer s2.Close()
+
+	dir, err := ioutil.TempDir("", "prometheus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := filepath.Join(dir, "prometheus.toml")
+	err = ioutil.WriteFile(conf, []byte(fmt.Sprintf(`urls = [%q, %q]
+concurrency = 2
+
+[[metrics]]
+name = "node_load1"
+`, s1.URL, s2.URL)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewPrometheus(PrometheusConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := in.FetchMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]bool)
+	for _, m := range metrics {
+		names[m.Name] = true
+	}
+
+	for _, u := range []string{s1.URL, s2.URL} {
+		name := fmt.Sprintf("%s.node_load1", endpointName(u))
+		if !names[name] {
+			t.Errorf("%s not found in %v", name, names)
+		}
+	}
+}
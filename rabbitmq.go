@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"net/http"
+	"strings"
+)
+
+var (
+	rabbitMQOverviewFields = []string{"queue_totals.messages", "queue_totals.messages_ready",
+		"queue_totals.messages_unacknowledged", "object_totals.connections", "object_totals.channels",
+		"object_totals.queues", "object_totals.consumers", "object_totals.exchanges"}
+	rabbitMQQueueFields = []string{"messages", "messages_ready", "messages_unacknowledged", "consumers", "memory"}
+	rabbitMQNodeFields  = []string{"running", "mem_used", "mem_limit", "mem_alarm", "fd_used", "fd_total",
+		"sockets_used", "sockets_total", "proc_used", "proc_total", "disk_free", "disk_free_limit", "disk_free_alarm"}
+)
+
+type RabbitMQ struct {
+	client *http.Client
+	config RabbitMQConfig
+	vhosts Filter
+	queues Filter
+	log    Logger
+}
+
+// RabbitMQConfig reads /api/overview, /api/queues and /api/nodes of the management plugin at URL.
+// Metrics are named rabbitmq.overview.<stat>, rabbitmq.queues.<vhost>.<queue>.<stat> and
+// rabbitmq.nodes.<node>.<stat>, the default vhost "/" is named "default". Message rates are reported
+// as <stat>_rate, e.g. overview.publish_rate, and alarms as 1 or 0. Metrics select stats without the
+// "rabbitmq." prefix (e.g. "queues.default.*.messages"), every stat is reported if none are given.
+type RabbitMQConfig struct {
+	URL          string                  `toml:"url"`
+	User         string                  `toml:"user"`
+	Password     string                  `toml:"password"`
+	Timeout      int                     `toml:"timeout"`
+	Concurrency  int                     `toml:"concurrency"`
+	VhostInclude string                  `toml:"vhost_include"`
+	VhostExclude string                  `toml:"vhost_exclude"`
+	QueueInclude string                  `toml:"queue_include"`
+	QueueExclude string                  `toml:"queue_exclude"`
+	Metrics      []RabbitMQMetricsConfig `toml:"metrics"`
+	TLSConfig
+	TimestampConfig
+}
+
+type RabbitMQMetricsConfig struct {
+	MetricsConfig
+}
+
+func NewRabbitMQ(rmqConfig RabbitMQConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var rmq *RabbitMQ
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return rmq, err
+	}
+
+	var config RabbitMQConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return rmq, err
+	}
+
+	if rmqConfig.URL != "" {
+		config.URL = rmqConfig.URL
+	} else if config.URL == "" {
+		config.URL = "http://localhost:15672"
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+
+	if rmqConfig.User != "" {
+		config.User = rmqConfig.User
+	} else if config.User == "" {
+		config.User = "guest"
+	}
+
+	if rmqConfig.Password != "" {
+		config.Password = rmqConfig.Password
+	} else if config.Password == "" {
+		config.Password = "guest"
+	}
+
+	if rmqConfig.Timeout > 0 {
+		config.Timeout = rmqConfig.Timeout
+	} else if config.Timeout == 0 {
+		config.Timeout = 10
+	}
+
+	if rmqConfig.Concurrency > 0 {
+		config.Concurrency = rmqConfig.Concurrency
+	}
+
+	for i := range config.Metrics {
+		if config.Metrics[i].Prefix == "" {
+			config.Metrics[i].Prefix = "rabbitmq"
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return rmq, err
+	}
+
+	rmq = &RabbitMQ{
+		config: config,
+		log:    log,
+	}
+
+	rmq.vhosts, err = NewFilter(config.VhostInclude, config.VhostExclude)
+	if err != nil {
+		return rmq, err
+	}
+
+	rmq.queues, err = NewFilter(config.QueueInclude, config.QueueExclude)
+	if err != nil {
+		return rmq, err
+	}
+
+	rmq.client, err = NewHTTPClient(config.TLSConfig, config.Timeout)
+
+	return rmq, err
+}
+
+func (rmq *RabbitMQ) get(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", rmq.config.URL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(rmq.config.User, rmq.config.Password)
+
+	return getJSON(rmq.client, req, v)
+}
+
+// setFields copies the fields (dotted paths) of data to stats as <prefix>.<field>.
+func setFields(stats map[string]float64, prefix string, data map[string]interface{}, fields []string) {
+	for _, field := range fields {
+		for name, v := range LookupJSONPath(data, strings.Split(field, "."), "") {
+			if fval, ok := JSONValue(v, nil); ok {
+				stats[fmt.Sprintf("%s.%s", prefix, name)] = fval
+			}
+		}
+	}
+}
+
+// setMessageRates copies the rates of message_stats, e.g. publish_details.rate to <prefix>.publish_rate.
+func setMessageRates(stats map[string]float64, prefix string, data map[string]interface{}) {
+	messageStats, ok := data["message_stats"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for key, v := range messageStats {
+		details, ok := v.(map[string]interface{})
+		if !ok || !strings.HasSuffix(key, "_details") {
+			continue
+		}
+
+		if fval, ok := JSONValue(details["rate"], nil); ok {
+			stats[fmt.Sprintf("%s.%s_rate", prefix, strings.TrimSuffix(key, "_details"))] = fval
+		}
+	}
+}
+
+func rabbitMQVhostName(vhost string) string {
+	if name := sanitizeName(vhost); name != "" {
+		return name
+	}
+
+	return "default"
+}
+
+func (rmq *RabbitMQ) overview() (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	var data map[string]interface{}
+	err := rmq.get("/api/overview", &data)
+	if err != nil {
+		return stats, err
+	}
+
+	setFields(stats, "overview", data, rabbitMQOverviewFields)
+	setMessageRates(stats, "overview", data)
+
+	return stats, nil
+}
+
+func (rmq *RabbitMQ) queueStats() (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	var data []map[string]interface{}
+	err := rmq.get("/api/queues", &data)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, queue := range data {
+		vhost, _ := queue["vhost"].(string)
+		name, _ := queue["name"].(string)
+		if !rmq.vhosts.Match(vhost) || !rmq.queues.Match(name) {
+			continue
+		}
+
+		prefix := fmt.Sprintf("queues.%s.%s", rabbitMQVhostName(vhost), sanitizeName(name))
+		setFields(stats, prefix, queue, rabbitMQQueueFields)
+		setMessageRates(stats, prefix, queue)
+	}
+
+	return stats, nil
+}
+
+func (rmq *RabbitMQ) nodeStats() (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	var data []map[string]interface{}
+	err := rmq.get("/api/nodes", &data)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, node := range data {
+		name, _ := node["name"].(string)
+		setFields(stats, fmt.Sprintf("nodes.%s", sanitizeName(name)), node, rabbitMQNodeFields)
+	}
+
+	return stats, nil
+}
+
+func (rmq *RabbitMQ) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	sections := []Section{
+		{Name: "overview", Fetch: rmq.overview},
+		{Name: "queues", Fetch: rmq.queueStats},
+		{Name: "nodes", Fetch: rmq.nodeStats},
+	}
+
+	results, errs, err := FetchSections(rmq.config.Concurrency, sections)
+
+	stats := make(map[string]float64)
+	for i, s := range sections {
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("rabbitmq.%s", s.Name), errs[i], now)...)
+		for k, v := range results[i] {
+			stats[k] = v
+		}
+	}
+
+	if len(rmq.config.Metrics) == 0 {
+		for _, m := range AllMetrics(stats, now) {
+			m.Name = fmt.Sprintf("rabbitmq.%s", m.Name)
+			metrics = append(metrics, m)
+		}
+	}
+
+	for _, m := range rmq.config.Metrics {
+		metrics = append(metrics, m.Metrics(stats, now)...)
+	}
+
+	return rmq.config.Apply(metrics, now), err
+}
+
+func (rmq *RabbitMQ) Teardown() {
+
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// responses of the management plugin of RabbitMQ 3.7, shortened
+var rabbitMQResponses = map[string]string{
+	"/api/overview": `{
+  "management_version": "3.7.8",
+  "message_stats": {
+    "publish": 120,
+    "publish_details": {"rate": 2.4},
+    "deliver_get": 118,
+    "deliver_get_details": {"rate": 2.2}
+  },
+  "queue_totals": {
+    "messages": 15,
+    "messages_details": {"rate": 0.0},
+    "messages_ready": 12,
+    "messages_unacknowledged": 3
+  },
+  "object_totals": {"channels": 4, "connections": 2, "consumers": 3, "exchanges": 8, "queues": 3}
+}`,
+	"/api/queues": `[
+  {
+    "name": "mail",
+    "vhost": "/",
+    "messages": 10,
+    "messages_ready": 8,
+    "messages_unacknowledged": 2,
+    "consumers": 1,
+    "memory": 34512,
+    "message_stats": {"publish": 100, "publish_details": {"rate": 2.0}}
+  },
+  {
+    "name": "jobs.high",
+    "vhost": "app",
+    "messages": 5,
+    "messages_ready": 4,
+    "messages_unacknowledged": 1,
+    "consumers": 2,
+    "memory": 21000
+  },
+  {
+    "name": "amq.gen-JzTY20BRgKO",
+    "vhost": "app",
+    "messages": 0,
+    "consumers": 0,
+    "memory": 1000
+  }
+]`,
+	"/api/nodes": `[
+  {
+    "name": "rabbit@mq1",
+    "running": true,
+    "mem_used": 85000000,
+    "mem_limit": 3000000000,
+    "mem_alarm": false,
+    "fd_used": 35,
+    "fd_total": 1024,
+    "disk_free": 50000000000,
+    "disk_free_limit": 50000000,
+    "disk_free_alarm": false
+  }
+]`,
+}
+
+func TestRabbitMQFetchMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		failing string
+		want    map[string]float64
+		missing []string
+		wantErr bool
+	}{
+		{
+			name: "all stats",
+			want: map[string]float64{
+				"rabbitmq.overview.up":                           1,
+				"rabbitmq.queues.up":                             1,
+				"rabbitmq.nodes.up":                              1,
+				"rabbitmq.overview.queue_totals.messages":        15,
+				"rabbitmq.overview.object_totals.connections":    2,
+				"rabbitmq.overview.publish_rate":                 2.4,
+				"rabbitmq.overview.deliver_get_rate":             2.2,
+				"rabbitmq.queues.default.mail.messages":          10,
+				"rabbitmq.queues.default.mail.publish_rate":      2,
+				"rabbitmq.queues.app.jobs_high.consumers":        2,
+				"rabbitmq.queues.app.amq_gen-JzTY20BRgKO.memory": 1000,
+				"rabbitmq.nodes.rabbit_mq1.running":              1,
+				"rabbitmq.nodes.rabbit_mq1.mem_alarm":            0,
+				"rabbitmq.nodes.rabbit_mq1.fd_used":              35,
+				"rabbitmq.nodes.rabbit_mq1.disk_free_alarm":      0,
+			},
+			missing: []string{"rabbitmq.overview.messages_rate"},
+		},
+		{
+			name:   "filtered queues",
+			config: "queue_exclude = \"^amq\\\\.\"\nvhost_include = \"^app$\"\n",
+			want: map[string]float64{
+				"rabbitmq.queues.app.jobs_high.messages": 5,
+			},
+			missing: []string{
+				"rabbitmq.queues.default.mail.messages",
+				"rabbitmq.queues.app.amq_gen-JzTY20BRgKO.memory",
+			},
+		},
+		{
+			name:   "selected metrics",
+			config: "[[metrics]]\nname = \"queues.*.*.messages\"\n",
+			want: map[string]float64{
+				"rabbitmq.queues.default.mail.messages": 10,
+				"rabbitmq.nodes.up":                     1,
+			},
+			missing: []string{"rabbitmq.overview.queue_totals.messages"},
+		},
+		{
+			name:    "a failing section",
+			failing: "/api/nodes",
+			want: map[string]float64{
+				"rabbitmq.nodes.up":                     0,
+				"rabbitmq.overview.up":                  1,
+				"rabbitmq.queues.default.mail.messages": 10,
+			},
+			missing: []string{"rabbitmq.nodes.rabbit_mq1.running"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if user, password, ok := r.BasicAuth(); !ok || user != "monitor" || password != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				body, ok := rabbitMQResponses[r.URL.Path]
+				if !ok || r.URL.Path == tt.failing {
+					http.Error(w, `{"error":"Object Not Found","reason":"Not Found"}`, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, body)
+			}))
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "rabbitmq")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			conf := filepath.Join(dir, "rabbitmq.toml")
+			err = ioutil.WriteFile(conf, []byte(fmt.Sprintf("url = %q\nuser = \"monitor\"\npassword = \"secret\"\n%s",
+				server.URL+"/", tt.config)), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in, err := NewRabbitMQ(RabbitMQConfig{}, conf, NewLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metrics, err := in.FetchMetrics()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+
+			stats := make(map[string]float64)
+			for _, m := range metrics {
+				stats[m.Name] = m.Value.(float64)
+			}
+
+			for name, want := range tt.want {
+				if got, ok := stats[name]; !ok || got != want {
+					t.Errorf("%s = %v (found %v), want %v", name, got, ok, want)
+				}
+			}
+
+			for _, name := range tt.missing {
+				if _, ok := stats[name]; ok {
+					t.Errorf("unexpected %s", name)
+				}
+			}
+		})
+	}
+}
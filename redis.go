@@ -21,8 +21,8 @@ type RedisConfig struct {
 	Port     int                  `toml:"port"`
 	Password string               `toml:"password"`
 	Timeout  int                  `toml:"timeout"`
-	Timezone string               `toml:"timezone"`
 	Metrics  []RedisMetricsConfig `toml:"metrics"`
+	TimestampConfig
 }
 
 type RedisMetricsConfig struct {
@@ -64,8 +64,9 @@ func NewRedis(redisConfig RedisConfig, filename string, log Logger) (Input, erro
 		config.Timeout = redisConfig.Timeout
 	}
 
-	if redisConfig.Timezone != "" {
-		config.Timezone = redisConfig.Timezone
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return r, err
 	}
 
 	client := redis.NewClient(&redis.Options{
@@ -137,15 +138,13 @@ func (r *Redis) info() (map[string]float64, error) {
 }
 
 func (r *Redis) FetchMetrics() ([]Metric, error) {
-	var err error
-	var now time.Time
+	now := ScheduledNow()
+	metrics := make([]Metric, 0, len(r.config.Metrics)+1)
 
 	stats, err := r.info()
-	metrics := make([]Metric, 0, len(r.config.Metrics))
-
-	now, err = FixedTimezone(time.Now(), r.config.Timezone)
+	metrics = append(metrics, SectionMetrics("redis", err, now)...)
 	if err != nil {
-		r.log.Debug(err)
+		return r.config.Apply(metrics, now), err
 	}
 
 	for _, m := range r.config.Metrics {
@@ -162,7 +161,7 @@ func (r *Redis) FetchMetrics() ([]Metric, error) {
 		}
 	}
 
-	return metrics, err
+	return r.config.Apply(metrics, now), err
 }
 
 func (r *Redis) Teardown() {
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultRunInterval is the interval of the cron line metrics-sender usually runs from.
+const defaultRunInterval = time.Minute
+
+// DefaultSampleInterval spreads the samples over a run, samples taken back to back would be identical.
+func DefaultSampleInterval(samples int) time.Duration {
+	if samples < 2 {
+		return 0
+	}
+
+	return defaultRunInterval / time.Duration(samples)
+}
+
+// FetchSamples calls FetchMetrics samples times, interval apart, on the same input
+// and returns every collected point with its own timestamp.
+func FetchSamples(input Input, samples int, interval time.Duration, log Logger) ([]Metric, error) {
+	if samples < 1 {
+		samples = 1
+	}
+
+	var errs MultiError
+	metrics := make([]Metric, 0)
+	start := time.Now()
+
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			time.Sleep(start.Add(time.Duration(i) * interval).Sub(time.Now()))
+		}
+		scheduleSample(i, interval)
+
+		ms, err := input.FetchMetrics()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		log.Debug(fmt.Sprintf("sample %d/%d: ", i+1, samples), ms)
+
+		metrics = append(metrics, ms...)
+	}
+
+	return metrics, errs.ErrorOrNil()
+}
+
+// AggregateSamples reduces the points of each metric to one point per function
+// (min, max, avg, sum, count, last), named <metric>.<function>.
+// Non-numeric values and the up and error metrics only keep the last point, under their own name.
+func AggregateSamples(metrics []Metric, funcs []string) ([]Metric, error) {
+	for _, f := range funcs {
+		switch f {
+		case "min", "max", "avg", "sum", "count", "last":
+		default:
+			return metrics, fmt.Errorf("Invalid sample aggregation: %s", f)
+		}
+	}
+
+	names := make([]string, 0)
+	groups := make(map[string][]Metric)
+	for _, m := range metrics {
+		if _, ok := groups[m.Name]; !ok {
+			names = append(names, m.Name)
+		}
+		groups[m.Name] = append(groups[m.Name], m)
+	}
+
+	aggregated := make([]Metric, 0, len(names)*len(funcs))
+	for _, name := range names {
+		points := groups[name]
+		last := points[len(points)-1]
+
+		values := make([]float64, 0, len(points))
+		for _, p := range points {
+			if v, ok := p.Value.(float64); ok {
+				values = append(values, v)
+			}
+		}
+
+		if len(values) == 0 || IsHealthMetric(name) {
+			aggregated = append(aggregated, last)
+			continue
+		}
+
+		for _, f := range funcs {
+			aggregated = append(aggregated, Metric{
+				Name:  fmt.Sprintf("%s.%s", name, f),
+				Time:  last.Time,
+				Value: aggregate(f, values),
+			})
+		}
+	}
+
+	return aggregated, nil
+}
+
+func aggregate(f string, values []float64) float64 {
+	var sum float64
+	min := values[0]
+	max := values[0]
+	for _, v := range values {
+		sum += v
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+
+	switch f {
+	case "min":
+		return min
+	case "max":
+		return max
+	case "avg":
+		return sum / float64(len(values))
+	case "sum":
+		return sum
+	case "count":
+		return float64(len(values))
+	}
+
+	return values[len(values)-1]
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregateSamples(t *testing.T) {
+	t1 := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(30 * time.Second)
+
+	tests := []struct {
+		name    string
+		metrics []Metric
+		funcs   []string
+		want    []Metric
+		wantErr bool
+	}{
+		{
+			name: "numeric values",
+			metrics: []Metric{
+				{Name: "loadavg.1", Value: 1.0, Time: t1},
+				{Name: "loadavg.1", Value: 3.0, Time: t2},
+			},
+			funcs: []string{"min", "max", "avg", "sum", "count", "last"},
+			want: []Metric{
+				{Name: "loadavg.1.min", Value: 1.0, Time: t2},
+				{Name: "loadavg.1.max", Value: 3.0, Time: t2},
+				{Name: "loadavg.1.avg", Value: 2.0, Time: t2},
+				{Name: "loadavg.1.sum", Value: 4.0, Time: t2},
+				{Name: "loadavg.1.count", Value: 2.0, Time: t2},
+				{Name: "loadavg.1.last", Value: 3.0, Time: t2},
+			},
+		},
+		{
+			name: "health metrics keep their name and last value",
+			metrics: []Metric{
+				{Name: "mysql.slave_status.up", Value: 1.0, Time: t1},
+				{Name: "mysql.slave_status.error", Value: 0.0, Time: t1},
+				{Name: "mysql.slave_status.up", Value: 0.0, Time: t2},
+				{Name: "mysql.slave_status.error", Value: 1.0, Time: t2},
+			},
+			funcs: []string{"avg"},
+			want: []Metric{
+				{Name: "mysql.slave_status.up", Value: 0.0, Time: t2},
+				{Name: "mysql.slave_status.error", Value: 1.0, Time: t2},
+			},
+		},
+		{
+			name: "text values",
+			metrics: []Metric{
+				{Name: "zookeeper.zk_server_state", Value: "follower", Time: t1},
+				{Name: "zookeeper.zk_server_state", Value: "leader", Time: t2},
+			},
+			funcs: []string{"max"},
+			want: []Metric{
+				{Name: "zookeeper.zk_server_state", Value: "leader", Time: t2},
+			},
+		},
+		{
+			name:    "unknown function",
+			funcs:   []string{"median"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AggregateSamples(tt.metrics, tt.funcs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+// Sender sends metrics to the output.
+// Metrics that could not be sent are kept in the buffer and resent with the next batch.
+type Sender struct {
+	output Output
+	buffer Buffer
+	bufErr error
+	bucket string
+	log    Logger
+}
+
+func NewSender(output Output, bufferPath, bufferMode, bucket string, log Logger) *Sender {
+	buffer, bufErr := NewBuffer(bufferPath, bufferMode)
+	if bufErr != nil {
+		log.Warn(bufErr)
+	}
+
+	return &Sender{
+		output: output,
+		buffer: buffer,
+		bufErr: bufErr,
+		bucket: bucket,
+		log:    log,
+	}
+}
+
+func (s *Sender) resend() {
+	if s.bufErr != nil {
+		return
+	}
+
+	bufferedMetrics, err := s.buffer.Read(s.bucket, 10)
+
+	if err != nil {
+		if err.Error() != "Bucket not found" {
+			s.log.Warn(err)
+		}
+		return
+	}
+
+	for key, ms := range bufferedMetrics {
+		err = s.output.Send(ms)
+		if err != nil {
+			s.log.Warn(err)
+		} else {
+			err = s.buffer.Delete(s.bucket, key)
+			s.log.Debug(err)
+		}
+	}
+	s.log.Debug("bufferd metrics: ", bufferedMetrics)
+}
+
+func (s *Sender) Send(metrics []Metric) error {
+	s.resend()
+
+	err := s.output.Send(metrics)
+	if err != nil && s.bufErr == nil {
+		if bufErr := s.buffer.Write(s.bucket, metrics); bufErr != nil {
+			s.log.Warn(bufErr)
+		}
+	}
+
+	return err
+}
+
+func (s *Sender) Close() {
+	s.buffer.Close()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LineServer receives newline separated messages over UDP and TCP.
+// handle is called concurrently for every line.
+type LineServer struct {
+	udp    net.PacketConn
+	tcp    net.Listener
+	conns  map[net.Conn]bool
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	handle func(line string)
+	log    Logger
+}
+
+// NewLineServer starts listening on the given addresses, an empty address disables the protocol.
+func NewLineServer(udpAddr, tcpAddr string, handle func(line string), log Logger) (*LineServer, error) {
+	var err error
+	s := &LineServer{
+		conns:  make(map[net.Conn]bool),
+		handle: handle,
+		log:    log,
+	}
+
+	if udpAddr != "" {
+		s.udp, err = net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			return s, err
+		}
+
+		s.wg.Add(1)
+		go s.serveUDP()
+	}
+
+	if tcpAddr != "" {
+		s.tcp, err = net.Listen("tcp", tcpAddr)
+		if err != nil {
+			s.Close()
+			return s, err
+		}
+
+		s.wg.Add(1)
+		go s.serveTCP()
+	}
+
+	return s, err
+}
+
+func (s *LineServer) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				s.handle(line)
+			}
+		}
+	}
+}
+
+func (s *LineServer) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+func (s *LineServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			s.handle(line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		s.log.Debug(err)
+	}
+}
+
+// Close stops listening, closes open connections and waits for the handlers to finish.
+func (s *LineServer) Close() {
+	if s.udp != nil {
+		s.udp.Close()
+	}
+
+	if s.tcp != nil {
+		s.tcp.Close()
+	}
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// FlushEvery passes the metrics of input to flush every interval until done is closed.
+// On shutdown stop is called first, e.g. to close the server, so that everything received
+// until then is in the last flush.
+func FlushEvery(input Input, interval time.Duration, done <-chan struct{}, stop func(), flush func([]Metric)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var stopped bool
+		select {
+		case <-ticker.C:
+		case <-done:
+			stop()
+			stopped = true
+		}
+
+		metrics, _ := input.FetchMetrics()
+		if len(metrics) > 0 {
+			flush(metrics)
+		}
+
+		if stopped {
+			return
+		}
+	}
+}
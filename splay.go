@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// SplayDelay returns how long to wait before fetching so that hosts started by
+// the same cron line do not hit the backends in the same second.
+// The "hash" mode derives a stable delay from the target, "random" picks a new one every run.
+func SplayDelay(window time.Duration, mode, target string) (time.Duration, error) {
+	if window <= 0 {
+		return 0, nil
+	}
+
+	switch mode {
+	case "", "hash":
+		h := fnv.New64a()
+		h.Write([]byte(target))
+		return time.Duration(h.Sum64() % uint64(window)), nil
+	case "random":
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		return time.Duration(r.Int63n(int64(window))), nil
+	}
+
+	return 0, fmt.Errorf("Invalid splay mode: %s", mode)
+}
+
+// Splay sleeps for delay, metrics stamped with ScheduledNow keep the time the run was scheduled for.
+func Splay(delay time.Duration) {
+	time.Sleep(delay)
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type SQL struct {
+	db       *sql.DB
+	config   SQLConfig
+	stateKey string
+	log      Logger
+}
+
+// SQLConfig runs Queries against a database/sql Driver ("mysql", "postgres" or "sqlite3") with the given DSN,
+// sqlite3 needs cgo and is only available when built with -tags sqlite3.
+// Metrics are named <prefix>.<query name>.<column> with sanitized names, the prefix defaults to "sql".
+type SQLConfig struct {
+	Driver      string           `toml:"driver"`
+	DSN         string           `toml:"dsn"`
+	Timeout     int              `toml:"timeout"`
+	Concurrency int              `toml:"concurrency"`
+	StatePath   string           `toml:"state_path"`
+	Prefix      string           `toml:"prefix"`
+	Queries     []SQLQueryConfig `toml:"queries"`
+	TimestampConfig
+}
+
+// SQLQueryConfig maps the numeric columns of the first row to metrics. With KeyColumn every row is
+// reported as <key>.<column>, and with Mode "key_value" the rows are two columns of a name and a value.
+// Timeout overrides the input timeout, a query with an Interval (seconds) runs at most once per interval.
+type SQLQueryConfig struct {
+	Name      string `toml:"name"`
+	Query     string `toml:"query"`
+	Mode      string `toml:"mode"`
+	KeyColumn string `toml:"key_column"`
+	Timeout   int    `toml:"timeout"`
+	Interval  int    `toml:"interval"`
+}
+
+func NewSQL(sqlConfig SQLConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var s *SQL
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return s, err
+	}
+
+	var config SQLConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return s, err
+	}
+
+	if sqlConfig.DSN != "" {
+		config.DSN = sqlConfig.DSN
+	}
+
+	if sqlConfig.Timeout > 0 {
+		config.Timeout = sqlConfig.Timeout
+	} else if config.Timeout == 0 {
+		config.Timeout = 10
+	}
+
+	if sqlConfig.Concurrency > 0 {
+		config.Concurrency = sqlConfig.Concurrency
+	}
+
+	if sqlConfig.StatePath != "" {
+		config.StatePath = sqlConfig.StatePath
+	} else if config.StatePath == "" {
+		config.StatePath = DefaultStatePath("sql")
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = "sql"
+	}
+
+	if config.Driver == "" {
+		return s, errors.New("driver is required")
+	}
+
+	seen := make(map[string]bool)
+	for i := range config.Queries {
+		q := &config.Queries[i]
+
+		if q.Name == "" || q.Query == "" {
+			return s, fmt.Errorf("queries[%d]: name and query are required", i)
+		}
+
+		if seen[q.Name] {
+			return s, fmt.Errorf("queries[%d]: duplicate name %s", i, q.Name)
+		}
+		seen[q.Name] = true
+
+		switch q.Mode {
+		case "":
+			q.Mode = "row"
+		case "row", "key_value":
+		default:
+			return s, fmt.Errorf("queries[%d]: unknown mode %s", i, q.Mode)
+		}
+
+		if q.Mode == "key_value" && q.KeyColumn != "" {
+			return s, fmt.Errorf("queries[%d]: key_column can not be used with mode key_value", i)
+		}
+
+		if q.Timeout == 0 {
+			q.Timeout = config.Timeout
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return s, err
+	}
+
+	// the state may be shared by several sql inputs, the intervals are kept per config file
+	var stateKey string
+	stateKey, err = filepath.Abs(filename)
+	if err != nil {
+		return s, err
+	}
+
+	var db *sql.DB
+	db, err = sql.Open(config.Driver, config.DSN)
+
+	s = &SQL{
+		db:       db,
+		config:   config,
+		stateKey: stateKey,
+		log:      log,
+	}
+
+	return s, err
+}
+
+// scanRows calls fn with the column names and the raw values of every row.
+func scanRows(rows *sql.Rows, fn func(columns []string, values []sql.RawBytes) error) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return err
+		}
+
+		err = fn(columns, values)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// fetchRows reads the numeric columns of the first row.
+func fetchRows(rows *sql.Rows) (map[string]float64, error) {
+	data := make(map[string]float64)
+
+	var found bool
+	err := scanRows(rows, func(columns []string, values []sql.RawBytes) error {
+		if found {
+			return nil
+		}
+		found = true
+
+		for i, val := range values {
+			fval, err := strconv.ParseFloat(string(val), 64)
+			if err != nil {
+				continue
+			}
+
+			data[columns[i]] = fval
+		}
+
+		return nil
+	})
+
+	if err == nil && !found {
+		err = errors.New("empty rows")
+	}
+
+	return data, err
+}
+
+// fetchKeyValues reads rows of a name and a numeric value, e.g. SHOW GLOBAL STATUS.
+func fetchKeyValues(rows *sql.Rows) (map[string]float64, error) {
+	data := make(map[string]float64)
+
+	err := scanRows(rows, func(columns []string, values []sql.RawBytes) error {
+		if len(values) != 2 {
+			return fmt.Errorf("expected 2 columns, got %d", len(values))
+		}
+
+		fval, err := strconv.ParseFloat(string(values[1]), 64)
+		if err == nil {
+			data[string(values[0])] = fval
+		}
+
+		return nil
+	})
+
+	return data, err
+}
+
+// fetchKeyedRows reads the numeric columns of every row as <keyColumn value>.<column>, both sanitized.
+func fetchKeyedRows(rows *sql.Rows, keyColumn string) (map[string]float64, error) {
+	data := make(map[string]float64)
+
+	err := scanRows(rows, func(columns []string, values []sql.RawBytes) error {
+		var key string
+		var found bool
+		for i, column := range columns {
+			if column == keyColumn {
+				key = sanitizeName(string(values[i]))
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("key column %s not found", keyColumn)
+		}
+
+		for i, val := range values {
+			if columns[i] == keyColumn {
+				continue
+			}
+
+			fval, err := strconv.ParseFloat(string(val), 64)
+			if err != nil {
+				continue
+			}
+
+			data[fmt.Sprintf("%s.%s", key, sanitizeName(columns[i]))] = fval
+		}
+
+		return nil
+	})
+
+	return data, err
+}
+
+func (s *SQL) query(q SQLQueryConfig) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(q.Timeout)*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, q.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if q.KeyColumn != "" {
+		return fetchKeyedRows(rows, q.KeyColumn)
+	}
+
+	var stats map[string]float64
+	if q.Mode == "key_value" {
+		stats, err = fetchKeyValues(rows)
+	} else {
+		stats, err = fetchRows(rows)
+	}
+
+	// e.g. count(*) or names with spaces
+	data := make(map[string]float64, len(stats))
+	for key, value := range stats {
+		data[sanitizeName(key)] = value
+	}
+
+	return data, err
+}
+
+func (s *SQL) queryStateKey(q SQLQueryConfig) string {
+	return fmt.Sprintf("%s:%s", s.stateKey, q.Name)
+}
+
+// due returns the queries whose interval has passed since their last successful run.
+func (s *SQL) due(state *State, now time.Time) ([]SQLQueryConfig, error) {
+	queries := make([]SQLQueryConfig, 0, len(s.config.Queries))
+	for _, q := range s.config.Queries {
+		if q.Interval > 0 && state != nil {
+			var last int64
+			found, err := state.Get("sql", s.queryStateKey(q), &last)
+			if err != nil {
+				return queries, err
+			}
+
+			if found && now.Unix()-last < int64(q.Interval) {
+				continue
+			}
+		}
+
+		queries = append(queries, q)
+	}
+
+	return queries, nil
+}
+
+func (s *SQL) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	var state *State
+	for _, q := range s.config.Queries {
+		if q.Interval > 0 {
+			st, err := NewState(s.config.StatePath)
+			if err != nil {
+				return metrics, err
+			}
+			defer st.Close()
+
+			state = &st
+			break
+		}
+	}
+
+	queries, err := s.due(state, now)
+	if err != nil {
+		return metrics, err
+	}
+
+	results := make([]map[string]float64, len(queries))
+	errs := make([]error, len(queries))
+	RunParallel(s.config.Concurrency, len(queries), func(i int) error {
+		results[i], errs[i] = s.query(queries[i])
+		return errs[i]
+	})
+
+	var merr MultiError
+	for i, q := range queries {
+		name := sanitizeName(q.Name)
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("%s.%s", s.config.Prefix, name), errs[i], now)...)
+		if errs[i] != nil {
+			merr = append(merr, fmt.Errorf("%s: %s", q.Name, errs[i]))
+			continue
+		}
+
+		for _, key := range sortedKeys(results[i]) {
+			metrics = append(metrics, Metric{
+				Name:  fmt.Sprintf("%s.%s.%s", s.config.Prefix, name, key),
+				Value: results[i][key],
+				Time:  now,
+			})
+		}
+
+		if q.Interval > 0 {
+			if err = state.Put("sql", s.queryStateKey(q), now.Unix()); err != nil {
+				merr = append(merr, err)
+			}
+		}
+	}
+
+	return s.config.Apply(metrics, now), merr.ErrorOrNil()
+}
+
+func (s *SQL) Teardown() {
+	_ = s.db.Close()
+}
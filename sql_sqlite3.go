@@ -0,0 +1,9 @@
+//go:build sqlite3
+// +build sqlite3
+
+package main
+
+// the sqlite3 driver needs cgo, so it is only built with -tags sqlite3
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// stubResults are the results of the queries of the "sqlstub" driver.
+var stubResults = map[string]stubRows{
+	"status": {
+		columns: []string{"Variable_name", "Value"},
+		values: [][]driver.Value{
+			{"Threads_connected", "4"},
+			{"Uptime", int64(3600)},
+			{"Ssl_cipher", ""},
+			{"Innodb_buffer_pool_pages_free", nil},
+		},
+	},
+	"counts": {
+		columns: []string{"count(*)", "max lag", "host"},
+		values: [][]driver.Value{
+			{int64(42), 1.5, "db1"},
+			{int64(1), 0.0, "db2"},
+		},
+	},
+	"tables": {
+		columns: []string{"table_name", "rows", "data length"},
+		values: [][]driver.Value{
+			{"users", int64(100), []byte("16384")},
+			{"app.sessions", int64(7), nil},
+		},
+	},
+	"empty": {
+		columns: []string{"count"},
+	},
+	"three_columns": {
+		columns: []string{"a", "b", "c"},
+		values:  [][]driver.Value{{"x", "1", "2"}},
+	},
+}
+
+type stubDriver struct{}
+
+type stubConn struct{}
+
+type stubRows struct {
+	columns []string
+	values  [][]driver.Value
+	i       int
+}
+
+func init() {
+	sql.Register("sqlstub", stubDriver{})
+}
+
+func (stubDriver) Open(dsn string) (driver.Conn, error) {
+	return stubConn{}, nil
+}
+
+func (stubConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (stubConn) Close() error {
+	return nil
+}
+
+func (stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (stubConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	rows, ok := stubResults[query]
+	if !ok {
+		return nil, fmt.Errorf("unknown query %s", query)
+	}
+
+	return &rows, nil
+}
+
+func (r *stubRows) Columns() []string {
+	return r.columns
+}
+
+func (r *stubRows) Close() error {
+	return nil
+}
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.i])
+	r.i++
+
+	return nil
+}
+
+func TestSQLRowMappers(t *testing.T) {
+	db, err := sql.Open("sqlstub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name    string
+		query   string
+		fetch   func(rows *sql.Rows) (map[string]float64, error)
+		want    map[string]float64
+		wantErr bool
+	}{
+		{
+			name:  "first row",
+			query: "counts",
+			fetch: fetchRows,
+			want:  map[string]float64{"count(*)": 42, "max lag": 1.5},
+		},
+		{
+			name:    "no rows",
+			query:   "empty",
+			fetch:   fetchRows,
+			want:    map[string]float64{},
+			wantErr: true,
+		},
+		{
+			name:  "key values",
+			query: "status",
+			fetch: fetchKeyValues,
+			want:  map[string]float64{"Threads_connected": 4, "Uptime": 3600},
+		},
+		{
+			name:    "key values of more than two columns",
+			query:   "three_columns",
+			fetch:   fetchKeyValues,
+			want:    map[string]float64{},
+			wantErr: true,
+		},
+		{
+			name:  "keyed rows",
+			query: "tables",
+			fetch: func(rows *sql.Rows) (map[string]float64, error) {
+				return fetchKeyedRows(rows, "table_name")
+			},
+			want: map[string]float64{
+				"users.rows":        100,
+				"users.data_length": 16384,
+				"app_sessions.rows": 7,
+			},
+		},
+		{
+			name:  "keyed rows without the key column",
+			query: "tables",
+			fetch: func(rows *sql.Rows) (map[string]float64, error) {
+				return fetchKeyedRows(rows, "name")
+			},
+			want:    map[string]float64{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.Query(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			got, err := tt.fetch(rows)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLFetchMetricsInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := filepath.Join(dir, "sql.toml")
+	err = ioutil.WriteFile(conf, []byte(fmt.Sprintf(`driver = "sqlstub"
+state_path = %q
+
+[[queries]]
+name = "status"
+query = "status"
+mode = "key_value"
+
+[[queries]]
+name = "table stats"
+query = "tables"
+key_column = "table_name"
+interval = 300
+
+[[queries]]
+name = "broken"
+query = "missing"
+interval = 300
+`, filepath.Join(dir, "state.db"))), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewSQL(SQLConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Teardown()
+
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	defer ScheduleRun(time.Time{})
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  []string
+	}{
+		{
+			name: "first run",
+			want: []string{
+				"sql.status.up", "sql.status.error", "sql.status.Threads_connected", "sql.status.Uptime",
+				"sql.table_stats.up", "sql.table_stats.error", "sql.table_stats.users.rows",
+				"sql.table_stats.users.data_length", "sql.table_stats.app_sessions.rows",
+				"sql.broken.up", "sql.broken.error",
+			},
+		},
+		{
+			// a failed query is retried on the next run
+			name:  "within the interval",
+			after: time.Minute,
+			want: []string{
+				"sql.status.up", "sql.status.error", "sql.status.Threads_connected", "sql.status.Uptime",
+				"sql.broken.up", "sql.broken.error",
+			},
+		},
+		{
+			name:  "after the interval",
+			after: 5 * time.Minute,
+			want: []string{
+				"sql.status.up", "sql.status.error", "sql.status.Threads_connected", "sql.status.Uptime",
+				"sql.table_stats.up", "sql.table_stats.error", "sql.table_stats.users.rows",
+				"sql.table_stats.users.data_length", "sql.table_stats.app_sessions.rows",
+				"sql.broken.up", "sql.broken.error",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ScheduleRun(start.Add(tt.after))
+
+		metrics, err := in.FetchMetrics()
+		if err == nil {
+			t.Errorf("%s: expected the error of the broken query", tt.name)
+		}
+
+		names := make(map[string]bool)
+		for _, m := range metrics {
+			names[m.Name] = true
+		}
+
+		if len(names) != len(tt.want) {
+			t.Errorf("%s: metrics = %v, want %v", tt.name, names, tt.want)
+		}
+
+		for _, name := range tt.want {
+			if !names[name] {
+				t.Errorf("%s: %s not found", tt.name, name)
+			}
+		}
+	}
+}
+
+func TestSQLDue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	state, err := NewState(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	s := &SQL{
+		config: SQLConfig{Queries: []SQLQueryConfig{
+			{Name: "always"},
+			{Name: "never_run", Interval: 60},
+			{Name: "recent", Interval: 60},
+			{Name: "old", Interval: 60},
+		}},
+		stateKey: "/etc/metrics-sender/sql.toml",
+	}
+
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	for name, last := range map[string]time.Time{
+		"recent": now.Add(-59 * time.Second),
+		"old":    now.Add(-60 * time.Second),
+	} {
+		if err := state.Put("sql", s.queryStateKey(SQLQueryConfig{Name: name}), last.Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// another config file keeps its own intervals
+	if err := state.Put("sql", "/etc/metrics-sender/other.toml:never_run", now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	queries, err := s.due(&state, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0, len(queries))
+	for _, q := range queries {
+		got = append(got, q.Name)
+	}
+
+	want := []string{"always", "never_run", "old"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("due = %v, want %v", got, want)
+	}
+
+	// without a state every query runs
+	queries, err = s.due(nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != len(s.config.Queries) {
+		t.Errorf("due without state = %d queries, want %d", len(queries), len(s.config.Queries))
+	}
+}
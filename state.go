@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State keeps values between runs, e.g. previous counters or file offsets.
+type State struct {
+	db *bolt.DB
+}
+
+func DefaultStatePath(name string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("metrics-sender_%s_state.db", name))
+}
+
+func NewState(path string) (State, error) {
+	s := State{}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+
+	s.db = db
+	return s, err
+}
+
+// Get decodes the value stored for key into v, it returns false if there is none.
+func (s *State) Get(bucketName, key string, v interface{}) (bool, error) {
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		value := bucket.Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(value, v)
+	})
+
+	return found, err
+}
+
+func (s *State) Put(bucketName, key string, v interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		if err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+// Prune deletes the keys of the bucket that are not in keep.
+func (s *State) Prune(bucketName string, keep map[string]bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		var keys [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			if !keep[string(k)] {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *State) Close() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
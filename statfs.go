@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+)
+
+func statfs(path string) (map[string]float64, error) {
+	return make(map[string]float64), errors.New("statfs is only supported on linux")
+}
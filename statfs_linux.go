@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"syscall"
+)
+
+func statfs(path string) (map[string]float64, error) {
+	var fs syscall.Statfs_t
+	stats := make(map[string]float64)
+
+	err := syscall.Statfs(path, &fs)
+	if err != nil {
+		return stats, err
+	}
+
+	bsize := float64(fs.Bsize)
+	size := float64(fs.Blocks) * bsize
+	free := float64(fs.Bfree) * bsize
+
+	stats["size"] = size
+	stats["used"] = size - free
+	stats["avail"] = float64(fs.Bavail) * bsize
+	stats["inodes"] = float64(fs.Files)
+	stats["inodes_free"] = float64(fs.Ffree)
+
+	// same as df: used / (used + available to non-root users)
+	if used := size - free; used+stats["avail"] > 0 {
+		stats["used_percent"] = used / (used + stats["avail"]) * 100
+	}
+
+	if fs.Files > 0 {
+		stats["inodes_used_percent"] = float64(fs.Files-fs.Ffree) / float64(fs.Files) * 100
+	}
+
+	return stats, err
+}
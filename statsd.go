@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// StatsD aggregates StatsD (and DogStatsD) lines received over UDP and TCP per flush interval.
+type StatsD struct {
+	config   StatsDConfig
+	interval time.Duration
+	nameTmpl *template.Template
+	server   *LineServer
+	series   map[string]*statsdSeries
+	last     time.Time
+	mu       sync.Mutex
+	done     chan struct{}
+	once     sync.Once
+	log      Logger
+}
+
+// StatsDConfig listens on UDPAddress (":8125" by default) and TCPAddress (disabled by default).
+// Gauges keep their last value across flushes unless DeleteGauges is set.
+// DogStatsD tags are flattened into the metric name with NameTemplate, like Prometheus labels.
+type StatsDConfig struct {
+	UDPAddress    string    `toml:"udp_address"`
+	TCPAddress    string    `toml:"tcp_address"`
+	FlushInterval string    `toml:"flush_interval"`
+	Percentiles   []float64 `toml:"percentiles"`
+	DeleteGauges  bool      `toml:"delete_gauges"`
+	NameTemplate  string    `toml:"name_template"`
+	TimestampConfig
+}
+
+type statsdSeries struct {
+	name    string
+	labels  map[string]string
+	kind    string
+	value   float64
+	count   float64
+	timings []float64
+	set     map[string]bool
+	updated bool
+}
+
+func NewStatsD(sdConfig StatsDConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var sd *StatsD
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return sd, err
+	}
+
+	var config StatsDConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return sd, err
+	}
+
+	if sdConfig.UDPAddress != "" {
+		config.UDPAddress = sdConfig.UDPAddress
+	} else if config.UDPAddress == "" && config.TCPAddress == "" {
+		config.UDPAddress = ":8125"
+	}
+
+	if sdConfig.TCPAddress != "" {
+		config.TCPAddress = sdConfig.TCPAddress
+	}
+
+	if sdConfig.FlushInterval != "" {
+		config.FlushInterval = sdConfig.FlushInterval
+	} else if config.FlushInterval == "" {
+		config.FlushInterval = "60s"
+	}
+
+	if config.Percentiles == nil {
+		config.Percentiles = []float64{90}
+	}
+
+	for _, p := range config.Percentiles {
+		if p <= 0 || p > 100 {
+			return sd, fmt.Errorf("Invalid percentile: %v", p)
+		}
+	}
+
+	var interval time.Duration
+	interval, err = time.ParseDuration(config.FlushInterval)
+	if err != nil {
+		return sd, err
+	}
+
+	if interval <= 0 {
+		return sd, fmt.Errorf("Invalid flush_interval: %s", config.FlushInterval)
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return sd, err
+	}
+
+	var tmpl *template.Template
+	tmpl, err = NewNameTemplate(config.NameTemplate)
+	if err != nil {
+		return sd, err
+	}
+
+	sd = &StatsD{
+		config:   config,
+		interval: interval,
+		nameTmpl: tmpl,
+		series:   make(map[string]*statsdSeries),
+		last:     time.Now(),
+		done:     make(chan struct{}),
+		log:      log,
+	}
+
+	return sd, err
+}
+
+// parseTags parses DogStatsD tags ("#env:prod,role"), a tag without value is used as its own value.
+func parseTags(s string) map[string]string {
+	labels := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+
+		kv := strings.SplitN(tag, ":", 2)
+		if len(kv) == 2 {
+			labels[kv[0]] = kv[1]
+		} else {
+			labels[kv[0]] = kv[0]
+		}
+	}
+
+	return labels
+}
+
+func seriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	key := name
+	for _, k := range keys {
+		key += fmt.Sprintf("|%s=%s", k, labels[k])
+	}
+
+	return key
+}
+
+// Handle parses a line formatted as <name>:<value>|<type>[|@<sample rate>][|#<tags>]
+// and adds it to the current flush interval.
+func (sd *StatsD) Handle(line string) error {
+	var err error
+
+	colon := strings.Index(line, ":")
+	if colon <= 0 {
+		return fmt.Errorf("Invalid line: %s", line)
+	}
+
+	name := line[:colon]
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 {
+		return fmt.Errorf("Invalid line: %s", line)
+	}
+
+	value, kind := parts[0], parts[1]
+	rate := 1.0
+	var labels map[string]string
+
+	for _, p := range parts[2:] {
+		switch {
+		case strings.HasPrefix(p, "@"):
+			rate, err = strconv.ParseFloat(p[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return fmt.Errorf("Invalid sample rate: %s", line)
+			}
+		case strings.HasPrefix(p, "#"):
+			labels = parseTags(p[1:])
+		}
+	}
+
+	var fval float64
+	if kind != "s" {
+		fval, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(fval) || math.IsInf(fval, 0) {
+			return fmt.Errorf("Invalid value: %s", line)
+		}
+	}
+
+	switch kind {
+	case "c", "g", "ms", "h", "s":
+	default:
+		return fmt.Errorf("Invalid type: %s", line)
+	}
+
+	if kind == "h" {
+		kind = "ms"
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	key := seriesKey(name, labels)
+	s, ok := sd.series[key]
+	if !ok || s.kind != kind {
+		s = &statsdSeries{
+			name:   name,
+			labels: labels,
+			kind:   kind,
+		}
+		sd.series[key] = s
+	}
+	s.updated = true
+
+	switch kind {
+	case "c":
+		s.value += fval / rate
+	case "g":
+		// a signed value modifies the current gauge
+		if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+			s.value += fval
+		} else {
+			s.value = fval
+		}
+	case "ms":
+		s.timings = append(s.timings, fval)
+		s.count += 1 / rate
+	case "s":
+		if s.set == nil {
+			s.set = make(map[string]bool)
+		}
+		s.set[value] = true
+	}
+
+	return nil
+}
+
+func (sd *StatsD) handle(line string) {
+	if err := sd.Handle(line); err != nil {
+		sd.log.Debug(err)
+	}
+}
+
+// flush returns the metrics aggregated since the previous flush and resets the counters, timers and sets.
+func (sd *StatsD) flush(now time.Time) []Metric {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	elapsed := time.Since(sd.last).Seconds()
+	sd.last = time.Now()
+
+	keys := make([]string, 0, len(sd.series))
+	for k := range sd.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	metrics := make([]Metric, 0, len(keys))
+	add := func(name string, value float64) {
+		metrics = append(metrics, Metric{
+			Name:  name,
+			Value: value,
+			Time:  now,
+		})
+	}
+
+	for _, k := range keys {
+		s := sd.series[k]
+
+		name, err := LabelName(sd.nameTmpl, s.name, s.labels, "")
+		if err != nil {
+			sd.log.Warn(err)
+			continue
+		}
+
+		switch s.kind {
+		case "c":
+			add(name, s.value)
+			if elapsed > 0 {
+				add(fmt.Sprintf("%s.rate", name), s.value/elapsed)
+			}
+			delete(sd.series, k)
+		case "g":
+			if s.updated || !sd.config.DeleteGauges {
+				add(name, s.value)
+			}
+			if sd.config.DeleteGauges {
+				delete(sd.series, k)
+			}
+			s.updated = false
+		case "ms":
+			sort.Float64s(s.timings)
+
+			var sum float64
+			for _, t := range s.timings {
+				sum += t
+			}
+
+			add(fmt.Sprintf("%s.count", name), s.count)
+			add(fmt.Sprintf("%s.sum", name), sum)
+			add(fmt.Sprintf("%s.mean", name), sum/float64(len(s.timings)))
+			add(fmt.Sprintf("%s.lower", name), s.timings[0])
+			add(fmt.Sprintf("%s.upper", name), s.timings[len(s.timings)-1])
+			for _, p := range sd.config.Percentiles {
+				add(fmt.Sprintf("%s.%s", name, quantileName(p/100)), Percentile(s.timings, p))
+			}
+			delete(sd.series, k)
+		case "s":
+			add(name, float64(len(s.set)))
+			delete(sd.series, k)
+		}
+	}
+
+	return metrics
+}
+
+// FetchMetrics returns the metrics aggregated since the previous flush.
+func (sd *StatsD) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	return sd.config.Apply(sd.flush(now), now), nil
+}
+
+// Listen receives metrics until Teardown is called, the pending metrics are flushed on shutdown.
+func (sd *StatsD) Listen(flush func([]Metric)) error {
+	var err error
+	sd.server, err = NewLineServer(sd.config.UDPAddress, sd.config.TCPAddress, sd.handle, sd.log)
+	if err != nil {
+		return err
+	}
+
+	FlushEvery(sd, sd.interval, sd.done, sd.server.Close, flush)
+
+	return nil
+}
+
+func (sd *StatsD) Teardown() {
+	sd.once.Do(func() {
+		close(sd.done)
+	})
+}
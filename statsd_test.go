@@ -0,0 +1,191 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStatsD(t *testing.T, config string) *StatsD {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := filepath.Join(dir, "statsd.toml")
+	if err := ioutil.WriteFile(conf, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewStatsD(StatsDConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return in.(*StatsD)
+}
+
+// flushStats flushes sd as if the interval started 10 seconds ago.
+func flushStats(sd *StatsD) map[string]float64 {
+	sd.last = time.Now().Add(-10 * time.Second)
+
+	stats := make(map[string]float64)
+	for _, m := range sd.flush(time.Now()) {
+		stats[m.Name] = m.Value.(float64)
+	}
+
+	return stats
+}
+
+func TestStatsDHandle(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  map[string]float64
+	}{
+		{
+			name:  "counter with sample rate",
+			lines: []string{"hits:1|c", "hits:2|c|@0.5"},
+			want: map[string]float64{
+				"hits":      5,
+				"hits.rate": 0.5,
+			},
+		},
+		{
+			name:  "timer with sample rate",
+			lines: []string{"req:100|ms|@0.5", "req:300|ms", "req:200|h"},
+			want: map[string]float64{
+				"req.count": 4,
+				"req.sum":   600,
+				"req.mean":  200,
+				"req.lower": 100,
+				"req.upper": 300,
+				"req.p90":   300,
+			},
+		},
+		{
+			name:  "signed gauge values modify the gauge",
+			lines: []string{"temp:10|g", "temp:+5|g", "temp:-3|g"},
+			want:  map[string]float64{"temp": 12},
+		},
+		{
+			name:  "set counts unique values",
+			lines: []string{"users:alice|s", "users:bob|s", "users:alice|s"},
+			want:  map[string]float64{"users": 2},
+		},
+		{
+			name:  "dogstatsd tags",
+			lines: []string{"hits:1|c|#env:prod,canary", "hits:1|c|@1|#env:dev"},
+			want: map[string]float64{
+				"hits.canary.prod":      1,
+				"hits.canary.prod.rate": 0.1,
+				"hits.dev":              1,
+				"hits.dev.rate":         0.1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd := newTestStatsD(t, "")
+
+			for _, line := range tt.lines {
+				if err := sd.Handle(line); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stats := flushStats(sd)
+			if len(stats) != len(tt.want) {
+				t.Errorf("stats = %v, want %v", stats, tt.want)
+			}
+
+			for name, want := range tt.want {
+				got, ok := stats[name]
+				// rates depend on the time the flush took
+				if !ok || (strings.HasSuffix(name, ".rate") && math.Abs(got-want) > want/100) ||
+					(!strings.HasSuffix(name, ".rate") && got != want) {
+					t.Errorf("%s = %v (found %v), want %v", name, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsDHandleInvalid(t *testing.T) {
+	sd := newTestStatsD(t, "")
+
+	for _, line := range []string{
+		"hits",
+		":1|c",
+		"hits:1",
+		"hits:x|c",
+		"hits:NaN|c",
+		"hits:1|x",
+		"hits:1|c|@0",
+		"hits:1|c|@2",
+	} {
+		if err := sd.Handle(line); err == nil {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+
+	if stats := flushStats(sd); len(stats) != 0 {
+		t.Errorf("stats = %v, want none", stats)
+	}
+}
+
+func TestStatsDFlush(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []map[string]float64
+	}{
+		{
+			name: "gauges are kept",
+			want: []map[string]float64{
+				{"hits": 1, "temp": 10, "users": 1, "req.count": 1},
+				{"temp": 10},
+			},
+		},
+		{
+			name:   "gauges are deleted",
+			config: "delete_gauges = true\n",
+			want: []map[string]float64{
+				{"hits": 1, "temp": 10, "users": 1, "req.count": 1},
+				{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd := newTestStatsD(t, tt.config)
+
+			for _, line := range []string{"hits:1|c", "temp:10|g", "users:alice|s", "req:5|ms"} {
+				if err := sd.Handle(line); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, want := range tt.want {
+				stats := flushStats(sd)
+
+				for name, v := range want {
+					if got, ok := stats[name]; !ok || got != v {
+						t.Errorf("flush %d: %s = %v (found %v), want %v", i, name, got, ok, v)
+					}
+				}
+
+				if i > 0 && len(stats) != len(want) {
+					t.Errorf("flush %d: stats = %v, want %v", i, stats, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var cpuFields = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal", "guest", "guest_nice"}
+
+var diskFields = []string{"reads_completed", "reads_merged", "sectors_read", "read_time",
+	"writes_completed", "writes_merged", "sectors_written", "write_time",
+	"io_in_progress", "io_time", "weighted_io_time"}
+
+var netFields = []string{"rx_bytes", "rx_packets", "rx_errs", "rx_drop", "rx_fifo", "rx_frame", "rx_compressed", "rx_multicast",
+	"tx_bytes", "tx_packets", "tx_errs", "tx_drop", "tx_fifo", "tx_colls", "tx_carrier", "tx_compressed"}
+
+var defaultExcludeFSTypes = "^(proc|sysfs|devtmpfs|devpts|tmpfs|cgroup2?|securityfs|pstore|debugfs|tracefs|mqueue|hugetlbfs|configfs|fusectl|autofs|binfmt_misc|rpc_pipefs|nsfs|overlay|squashfs|bpf)$"
+
+type System struct {
+	config     SystemConfig
+	devices    Filter
+	interfaces Filter
+	mounts     Filter
+	fsTypes    Filter
+	log        Logger
+}
+
+// SystemConfig reads from ProcRoot (default /proc), filesystems are looked up below RootFS,
+// so that fixture directories can be used instead of the live system.
+type SystemConfig struct {
+	ProcRoot         string                `toml:"proc_root"`
+	RootFS           string                `toml:"rootfs"`
+	StatePath        string                `toml:"state_path"`
+	DeviceInclude    string                `toml:"device_include"`
+	DeviceExclude    string                `toml:"device_exclude"`
+	InterfaceInclude string                `toml:"interface_include"`
+	InterfaceExclude string                `toml:"interface_exclude"`
+	MountInclude     string                `toml:"mount_include"`
+	MountExclude     string                `toml:"mount_exclude"`
+	FSTypeExclude    string                `toml:"fstype_exclude"`
+	Metrics          []SystemMetricsConfig `toml:"metrics"`
+	TimestampConfig
+}
+
+type SystemMetricsConfig struct {
+	MetricsConfig
+}
+
+func NewSystem(sysConfig SystemConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var s *System
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return s, err
+	}
+
+	var config SystemConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return s, err
+	}
+
+	if sysConfig.ProcRoot != "" {
+		config.ProcRoot = sysConfig.ProcRoot
+	} else if config.ProcRoot == "" {
+		config.ProcRoot = "/proc"
+	}
+
+	if sysConfig.StatePath != "" {
+		config.StatePath = sysConfig.StatePath
+	} else if config.StatePath == "" {
+		config.StatePath = DefaultStatePath("system")
+	}
+
+	if config.FSTypeExclude == "" {
+		config.FSTypeExclude = defaultExcludeFSTypes
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return s, err
+	}
+
+	s = &System{
+		config: config,
+		log:    log,
+	}
+
+	s.devices, err = NewFilter(config.DeviceInclude, config.DeviceExclude)
+	if err != nil {
+		return s, err
+	}
+
+	s.interfaces, err = NewFilter(config.InterfaceInclude, config.InterfaceExclude)
+	if err != nil {
+		return s, err
+	}
+
+	s.mounts, err = NewFilter(config.MountInclude, config.MountExclude)
+	if err != nil {
+		return s, err
+	}
+
+	s.fsTypes, err = NewFilter("", config.FSTypeExclude)
+
+	return s, err
+}
+
+func (s *System) procPath(name string) string {
+	return filepath.Join(s.config.ProcRoot, name)
+}
+
+func (s *System) readLines(name string) ([]string, error) {
+	var lines []string
+
+	f, err := os.Open(s.procPath(name))
+	if err != nil {
+		return lines, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
+
+func parseFloats(fields []string) []float64 {
+	values := make([]float64, 0, len(fields))
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			v = 0
+		}
+		values = append(values, v)
+	}
+
+	return values
+}
+
+func (s *System) loadavg(stats map[string]float64) error {
+	buf, err := ioutil.ReadFile(s.procPath("loadavg"))
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(buf))
+	if len(fields) < 4 {
+		return fmt.Errorf("Invalid loadavg: %s", buf)
+	}
+
+	values := parseFloats(fields[:3])
+	stats["loadavg.1"] = values[0]
+	stats["loadavg.5"] = values[1]
+	stats["loadavg.15"] = values[2]
+
+	procs := strings.SplitN(fields[3], "/", 2)
+	if len(procs) == 2 {
+		values = parseFloats(procs)
+		stats["procs.running"] = values[0]
+		stats["procs.total"] = values[1]
+	}
+
+	return nil
+}
+
+func (s *System) meminfo(stats map[string]float64) error {
+	lines, err := s.readLines("meminfo")
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+
+		if len(fields) > 2 && fields[2] == "kB" {
+			v *= 1024
+		}
+
+		stats[fmt.Sprintf("memory.%s", strings.TrimSuffix(fields[0], ":"))] = v
+	}
+
+	if total, ok := stats["memory.MemTotal"]; ok && total > 0 {
+		available, ok := stats["memory.MemAvailable"]
+		if !ok {
+			available = stats["memory.MemFree"] + stats["memory.Buffers"] + stats["memory.Cached"]
+		}
+
+		stats["memory.used"] = total - available
+		stats["memory.used_percent"] = (total - available) / total * 100
+	}
+
+	return nil
+}
+
+// stat reads /proc/stat, CPU percentages are computed against the CPU times of the previous run.
+func (s *System) stat(stats map[string]float64, state *State) error {
+	lines, err := s.readLines("stat")
+	if err != nil {
+		return err
+	}
+
+	cpu := make(map[string]float64)
+	for _, l := range lines {
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "cpu":
+			for i, v := range parseFloats(fields[1:]) {
+				if i < len(cpuFields) {
+					cpu[cpuFields[i]] = v
+				}
+			}
+		case "ctxt", "btime", "processes", "procs_running", "procs_blocked", "intr", "softirq":
+			stats[fmt.Sprintf("stat.%s", fields[0])] = parseFloats(fields[1:2])[0]
+		}
+	}
+
+	prev := make(map[string]float64)
+	found, err := state.Get("system", "cpu", &prev)
+	if err != nil {
+		s.log.Warn(err)
+	}
+
+	if err = state.Put("system", "cpu", cpu); err != nil {
+		s.log.Warn(err)
+	}
+
+	if !found {
+		return nil
+	}
+
+	// guest times are already included in user and nice
+	var total float64
+	for _, f := range cpuFields[:8] {
+		total += cpu[f] - prev[f]
+	}
+
+	if total <= 0 {
+		return nil
+	}
+
+	for _, f := range cpuFields {
+		stats[fmt.Sprintf("cpu.%s", f)] = (cpu[f] - prev[f]) / total * 100
+	}
+
+	return nil
+}
+
+func (s *System) diskstats(stats map[string]float64) error {
+	lines, err := s.readLines("diskstats")
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		fields := strings.Fields(l)
+		if len(fields) < 3+len(diskFields) || !s.devices.Match(fields[2]) {
+			continue
+		}
+
+		for i, v := range parseFloats(fields[3 : 3+len(diskFields)]) {
+			stats[fmt.Sprintf("disk.%s.%s", fields[2], diskFields[i])] = v
+		}
+	}
+
+	return nil
+}
+
+// readNetDev reads the counters of every interface in a net/dev file, indexed like netFields.
+func readNetDev(path string) (map[string][]float64, error) {
+	ifaces := make(map[string][]float64)
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ifaces, err
+	}
+
+	for _, l := range strings.Split(string(buf), "\n") {
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		fields := strings.Fields(kv[1])
+		if len(fields) < len(netFields) {
+			continue
+		}
+
+		ifaces[strings.TrimSpace(kv[0])] = parseFloats(fields[:len(netFields)])
+	}
+
+	return ifaces, nil
+}
+
+func (s *System) netdev(stats map[string]float64) error {
+	ifaces, err := readNetDev(s.procPath("net/dev"))
+	if err != nil {
+		return err
+	}
+
+	for iface, values := range ifaces {
+		if !s.interfaces.Match(iface) {
+			continue
+		}
+
+		for i, v := range values {
+			stats[fmt.Sprintf("net.%s.%s", iface, netFields[i])] = v
+		}
+	}
+
+	return nil
+}
+
+// snmp reads /proc/net/snmp, which consists of header and value line pairs per protocol.
+func (s *System) snmp(stats map[string]float64) error {
+	lines, err := s.readLines("net/snmp")
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(lines); i += 2 {
+		header := strings.Fields(lines[i])
+		values := strings.Fields(lines[i+1])
+		if len(header) != len(values) || len(header) == 0 || header[0] != values[0] {
+			return fmt.Errorf("Invalid net/snmp: %s", lines[i])
+		}
+
+		proto := strings.TrimSuffix(header[0], ":")
+		for j, v := range parseFloats(values[1:]) {
+			stats[fmt.Sprintf("snmp.%s.%s", proto, header[j+1])] = v
+		}
+	}
+
+	return nil
+}
+
+func mountName(mountpoint string) string {
+	if mountpoint == "/" {
+		return "root"
+	}
+
+	return sanitizeName(mountpoint)
+}
+
+func (s *System) filesystems(stats map[string]float64) error {
+	lines, err := s.readLines("mounts")
+	if err != nil {
+		return err
+	}
+
+	var errs MultiError
+	seen := make(map[string]bool)
+	for _, l := range lines {
+		fields := strings.Fields(l)
+		if len(fields) < 3 {
+			continue
+		}
+
+		mountpoint, fstype := fields[1], fields[2]
+		if seen[mountpoint] || !s.mounts.Match(mountpoint) || !s.fsTypes.Match(fstype) {
+			continue
+		}
+		seen[mountpoint] = true
+
+		fs, err := statfs(filepath.Join(s.config.RootFS, mountpoint))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", mountpoint, err))
+			continue
+		}
+
+		name := mountName(mountpoint)
+		for k, v := range fs {
+			stats[fmt.Sprintf("fs.%s.%s", name, k)] = v
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+func (s *System) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+	stats := make(map[string]float64)
+
+	state, err := NewState(s.config.StatePath)
+	if err != nil {
+		return metrics, err
+	}
+	defer state.Close()
+
+	var errs MultiError
+	for _, section := range []struct {
+		name  string
+		fetch func(map[string]float64) error
+	}{
+		{"loadavg", s.loadavg},
+		{"meminfo", s.meminfo},
+		{"stat", func(stats map[string]float64) error { return s.stat(stats, &state) }},
+		{"diskstats", s.diskstats},
+		{"netdev", s.netdev},
+		{"snmp", s.snmp},
+		{"filesystems", s.filesystems},
+	} {
+		err = section.fetch(stats)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", section.name, err))
+		}
+		metrics = append(metrics, SectionMetrics(fmt.Sprintf("system.%s", section.name), err, now)...)
+	}
+
+	if len(s.config.Metrics) == 0 {
+		metrics = append(metrics, AllMetrics(stats, now)...)
+	}
+
+	for _, m := range s.config.Metrics {
+		metrics = append(metrics, m.Metrics(stats, now)...)
+	}
+
+	return s.config.Apply(metrics, now), errs.ErrorOrNil()
+}
+
+func (s *System) Teardown() {
+
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// procFixture is a /proc snapshot of a small VM, shortened
+var procFixture = map[string]string{
+	"loadavg": "0.52 0.58 0.59 2/467 12345\n",
+	"meminfo": `MemTotal:        4000000 kB
+MemFree:          500000 kB
+MemAvailable:    1000000 kB
+Buffers:          100000 kB
+Cached:          1500000 kB
+HugePages_Total:       0
+`,
+	"stat": `cpu  1000 0 500 8000 100 0 0 0 0 0
+cpu0 1000 0 500 8000 100 0 0 0 0 0
+intr 123456 0 0
+ctxt 987654
+btime 1527811200
+processes 4321
+procs_running 2
+procs_blocked 0
+softirq 5555 0 0
+`,
+	"diskstats": `   8       0 sda 1000 10 20000 500 2000 20 40000 1500 0 1200 2000
+   8       1 sda1 900 10 18000 450 1900 20 38000 1400 0 1100 1850
+   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0
+`,
+	"net/dev": `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  5000      50    0    0    0     0          0         0     5000      50    0    0    0     0       0          0
+  eth0: 123456    1000    1    2    0     0          0         3    654321     900    4    5    0     0       0          0
+`,
+	"net/snmp": `Ip: Forwarding DefaultTTL InReceives
+Ip: 1 64 5000
+Tcp: RtoAlgorithm ActiveOpens RetransSegs
+Tcp: 1 100 7
+`,
+	"mounts": `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+`,
+}
+
+func writeProcFixture(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSystemFetchMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	procRoot := filepath.Join(dir, "proc")
+	writeProcFixture(t, procRoot, procFixture)
+
+	conf := filepath.Join(dir, "system.toml")
+	err = ioutil.WriteFile(conf, []byte(fmt.Sprintf("proc_root = %q\nrootfs = %q\nstate_path = %q\ndevice_exclude = \"^loop\"\n",
+		procRoot, dir, filepath.Join(dir, "state.db"))), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewSystem(SystemConfig{}, conf, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func() map[string]float64 {
+		metrics, err := in.FetchMetrics()
+		if err != nil && runtime.GOOS == "linux" {
+			t.Fatal(err)
+		}
+
+		stats := make(map[string]float64)
+		for _, m := range metrics {
+			stats[m.Name] = m.Value.(float64)
+		}
+
+		return stats
+	}
+
+	tests := []struct {
+		name    string
+		stat    string
+		want    map[string]float64
+		missing []string
+	}{
+		{
+			name: "first run",
+			want: map[string]float64{
+				"system.loadavg.up":          1,
+				"system.snmp.up":             1,
+				"loadavg.1":                  0.52,
+				"loadavg.15":                 0.59,
+				"procs.running":              2,
+				"procs.total":                467,
+				"memory.MemTotal":            4000000 * 1024,
+				"memory.HugePages_Total":     0,
+				"memory.used":                3000000 * 1024,
+				"memory.used_percent":        75,
+				"stat.ctxt":                  987654,
+				"stat.intr":                  123456,
+				"disk.sda.reads_completed":   1000,
+				"disk.sda1.weighted_io_time": 1850,
+				"net.eth0.rx_bytes":          123456,
+				"net.eth0.rx_multicast":      3,
+				"net.eth0.tx_drop":           5,
+				"net.lo.tx_packets":          50,
+				"snmp.Ip.InReceives":         5000,
+				"snmp.Tcp.RetransSegs":       7,
+			},
+			// CPU percentages need the times of the previous run
+			missing: []string{"cpu.user", "disk.loop0.reads_completed", "fs.proc.size", "fs.sys.size"},
+		},
+		{
+			name: "second run",
+			stat: "cpu  1300 0 600 8550 150 0 0 0 0 0\nctxt 999999\n",
+			want: map[string]float64{
+				"cpu.user":   30,
+				"cpu.system": 10,
+				"cpu.idle":   55,
+				"cpu.iowait": 5,
+				"cpu.steal":  0,
+				"stat.ctxt":  999999,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.stat != "" {
+			writeProcFixture(t, procRoot, map[string]string{"stat": tt.stat})
+		}
+
+		stats := fetch()
+		for name, want := range tt.want {
+			if got, ok := stats[name]; !ok || math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s: %s = %v (found %v), want %v", tt.name, name, got, ok, want)
+			}
+		}
+
+		for _, name := range tt.missing {
+			if _, ok := stats[name]; ok {
+				t.Errorf("%s: unexpected %s", tt.name, name)
+			}
+		}
+
+		if runtime.GOOS == "linux" {
+			if _, ok := stats["fs.root.size"]; !ok {
+				t.Errorf("%s: fs.root.size not found", tt.name)
+			}
+		}
+	}
+}
+
+func TestSystemMeminfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		meminfo string
+		want    map[string]float64
+	}{
+		{
+			name:    "with MemAvailable",
+			meminfo: "MemTotal: 1000 kB\nMemFree: 100 kB\nMemAvailable: 400 kB\n",
+			want: map[string]float64{
+				"memory.used":         600 * 1024,
+				"memory.used_percent": 60,
+			},
+		},
+		{
+			name:    "older kernels without MemAvailable",
+			meminfo: "MemTotal: 1000 kB\nMemFree: 100 kB\nBuffers: 50 kB\nCached: 250 kB\n",
+			want: map[string]float64{
+				"memory.used":         600 * 1024,
+				"memory.used_percent": 60,
+			},
+		},
+		{
+			name:    "no MemTotal",
+			meminfo: "MemFree: 100 kB\n",
+			want: map[string]float64{
+				"memory.MemFree": 100 * 1024,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "system")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			writeProcFixture(t, dir, map[string]string{"meminfo": tt.meminfo})
+
+			s := &System{config: SystemConfig{ProcRoot: dir}, log: NewLogger()}
+			stats := make(map[string]float64)
+			if err := s.meminfo(stats); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range tt.want {
+				if got, ok := stats[name]; !ok || got != want {
+					t.Errorf("%s = %v (found %v), want %v", name, got, ok, want)
+				}
+			}
+
+			if _, ok := tt.want["memory.used"]; !ok {
+				if _, found := stats["memory.used"]; found {
+					t.Error("unexpected memory.used")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tailPosition is the read position of a file kept in the state.
+type tailPosition struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// TailFile calls fn for every complete line appended to path since the previous call,
+// the position is kept in state. A file with another inode (rotated) or a file shorter than
+// the position (truncated) is read from the beginning. The lines written to a rotated file after
+// the previous call are read first if it is found next to path (e.g. path.1 or path-20180601).
+// On the first call the file is read from the end unless fromStart is set.
+func TailFile(state *State, path string, fromStart bool, fn func(line string)) error {
+	return TailFileEntries(state, path, fromStart, func(line string, offset int64) {
+		fn(line)
+	}, nil)
+}
+
+// TailFileEntries is TailFile for logs with multi-line entries, fn also gets the offset of the line.
+// After the last line of a file done is called with the end offset and returns the position to keep,
+// e.g. the offset of an entry that is not completely written yet, so it is read again with the next call.
+// For a rotated file rotated is set and the position is not kept, its last entry is complete.
+func TailFileEntries(state *State, path string, fromStart bool, fn func(line string, offset int64), done func(end int64, rotated bool) int64) error {
+	var pos tailPosition
+	found, err := state.Get("tail", path, &pos)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	inode := fileInode(fi)
+	switch {
+	case !found:
+		if !fromStart {
+			pos.Offset = fi.Size()
+		}
+	case pos.Inode != inode:
+		err = readRotated(path, pos, fn, done)
+		if err != nil {
+			return err
+		}
+		pos.Offset = 0
+	case fi.Size() < pos.Offset:
+		pos.Offset = 0
+	}
+	pos.Inode = inode
+
+	pos.Offset, err = readLines(f, pos.Offset, false, fn)
+	if err != nil {
+		return err
+	}
+
+	if done != nil {
+		pos.Offset = done(pos.Offset, false)
+	}
+
+	return state.Put("tail", path, pos)
+}
+
+// readLines calls fn for every line of f from offset and returns the offset after the last line.
+// The last line without a newline is only read if the file is no longer written (final).
+func readLines(f *os.File, offset int64, final bool, fn func(line string, offset int64)) (int64, error) {
+	_, err := f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return offset, err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && (!final || line == "") {
+			// an incomplete line is read with the next call
+			return offset, nil
+		}
+
+		if err != nil && err != io.EOF {
+			return offset, err
+		}
+
+		start := offset
+		offset += int64(len(line))
+		fn(strings.TrimRight(line, "\r\n"), start)
+	}
+}
+
+// readRotated reads the lines written to the rotated file of path after pos.
+func readRotated(path string, pos tailPosition, fn func(line string, offset int64), done func(end int64, rotated bool) int64) error {
+	rotated := rotatedFile(path, pos.Inode)
+	if rotated == "" {
+		return nil
+	}
+
+	f, err := os.Open(rotated)
+	if os.IsNotExist(err) {
+		// compressed or deleted in the meantime
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() < pos.Offset {
+		return nil
+	}
+
+	end, err := readLines(f, pos.Offset, true, fn)
+	if err != nil {
+		return err
+	}
+
+	if done != nil {
+		done(end, true)
+	}
+
+	return nil
+}
+
+// rotatedFile returns the file next to path with the given inode, e.g. path.1 of logrotate,
+// or "" if it is gone (compressed, deleted) or inodes are not supported.
+func rotatedFile(path string, inode uint64) string {
+	if inode == 0 {
+		return ""
+	}
+
+	candidates := []string{path + ".1"}
+	for _, pattern := range []string{path + ".*", path + "-*"} {
+		matches, _ := filepath.Glob(pattern)
+		candidates = append(candidates, matches...)
+	}
+
+	for _, c := range candidates {
+		fi, err := os.Stat(c)
+		if err == nil && fi.Mode().IsRegular() && fileInode(fi) == inode {
+			return c
+		}
+	}
+
+	return ""
+}
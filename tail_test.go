@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestTailFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rotated files are detected by inode on linux only")
+	}
+
+	dir, err := ioutil.TempDir("", "tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	state, err := NewState(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	path := filepath.Join(dir, "app.log")
+	appendLog := func(path, s string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	tests := []struct {
+		name   string
+		before func()
+		want   []string
+	}{
+		{
+			name:   "the incomplete last line is left for the next call",
+			before: func() { appendLog(path, "a\nb\nc") },
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "appended lines",
+			before: func() { appendLog(path, "\nd\n") },
+			want:   []string{"c", "d"},
+		},
+		{
+			name: "the rest of the rotated file is read first",
+			before: func() {
+				appendLog(path, "e\nf")
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendLog(path, "g\n")
+			},
+			want: []string{"e", "f", "g"},
+		},
+		{
+			name: "the rotated file is found by its inode",
+			before: func() {
+				appendLog(path, "h\n")
+				if err := os.Rename(path+".1", path+".2"); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(path, path+"-20180601"); err != nil {
+					t.Fatal(err)
+				}
+				appendLog(path, "i\n")
+			},
+			want: []string{"h", "i"},
+		},
+		{
+			name:   "lines appended after the rotation",
+			before: func() { appendLog(path, "j\n") },
+			want:   []string{"j"},
+		},
+		{
+			name: "a truncated file is read from the beginning",
+			before: func() {
+				if err := ioutil.WriteFile(path, []byte("k\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"k"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.before()
+
+		lines := make([]string, 0)
+		err := TailFile(&state, path, true, func(line string) {
+			lines = append(lines, line)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(lines, tt.want) {
+			t.Errorf("%s: lines = %v, want %v", tt.name, lines, tt.want)
+		}
+	}
+}
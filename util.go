@@ -1,27 +1,138 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"time"
 )
 
-func FixedTimezone(t time.Time, timezone string) (time.Time, error) {
+var (
+	// runStart is the run interval boundary the current run was started for
+	runStart time.Time
+	// scheduled is the collection time of the current sample
+	scheduled time.Time
+)
+
+// ScheduleRun aligns the collection times of a run started at start to the run interval,
+// e.g. a cron run started at 12:00:01 (or delayed by a splay) collects for 12:00:00.
+func ScheduleRun(start time.Time) {
+	runStart = start.Truncate(defaultRunInterval).UTC()
+	scheduled = runStart
+}
+
+// scheduleSample sets the collection time of the i-th sample of the run taken interval apart.
+func scheduleSample(i int, interval time.Duration) {
+	if !runStart.IsZero() {
+		scheduled = runStart.Add(time.Duration(i) * interval)
+	}
+}
+
+// ScheduledNow returns the collection time of the current sample in UTC,
+// outside of a scheduled run (e.g. listeners) it is the current time.
+func ScheduledNow() time.Time {
+	if scheduled.IsZero() {
+		return time.Now().UTC()
+	}
+
+	return scheduled
+}
+
+// TimestampConfig controls how inputs stamp their metrics.
+// Timestamps are always kept in UTC, the timezone is only used for display.
+type TimestampConfig struct {
+	// "input" keeps timestamps provided by the input (CloudWatch datapoints, command output),
+	// "collection" stamps every metric with the collection time
+	TimestampSource string `toml:"timestamp_source"`
+	// e.g. "10s", "60s"
+	TimestampAlign string `toml:"timestamp_align"`
+	// "truncate" or "round"
+	TimestampAlignMode string `toml:"timestamp_align_mode"`
+	align              time.Duration
+}
+
+func (tc *TimestampConfig) Setup() error {
 	var err error
-	var loc *time.Location
-	zone, offset := time.Now().In(time.Local).Zone()
 
-	if zone == "UTC" {
-		return t, err
+	switch tc.TimestampSource {
+	case "", "input", "collection":
+	default:
+		return fmt.Errorf("Invalid timestamp_source: %s", tc.TimestampSource)
+	}
+
+	switch tc.TimestampAlignMode {
+	case "", "truncate", "round":
+	default:
+		return fmt.Errorf("Invalid timestamp_align_mode: %s", tc.TimestampAlignMode)
+	}
+
+	if tc.TimestampAlign != "" {
+		tc.align, err = time.ParseDuration(tc.TimestampAlign)
+	}
+
+	return err
+}
+
+func (tc *TimestampConfig) AlignTime(t time.Time) time.Time {
+	t = t.UTC()
+
+	if tc.align <= 0 {
+		return t
+	}
+
+	if tc.TimestampAlignMode == "round" {
+		return t.Round(tc.align)
 	}
 
-	if timezone != "" {
-		loc, err = time.LoadLocation(timezone)
-		if err != nil {
-			return t, err
+	return t.Truncate(tc.align)
+}
+
+// Apply rewrites the timestamps of metrics according to the config.
+// collected is the time the input was queried.
+func (tc *TimestampConfig) Apply(metrics []Metric, collected time.Time) []Metric {
+	for i := range metrics {
+		if tc.TimestampSource == "collection" || metrics[i].Time.IsZero() {
+			metrics[i].Time = collected
 		}
 
-		return t.In(loc), err
+		metrics[i].Time = tc.AlignTime(metrics[i].Time)
+	}
+
+	return metrics
+}
+
+func LoadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(timezone)
+}
+
+// InTimezone returns a copy of metrics with timestamps converted for display.
+func InTimezone(metrics []Metric, loc *time.Location) []Metric {
+	ms := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		m.Time = m.Time.In(loc)
+		ms = append(ms, m)
+	}
+
+	return ms
+}
+
+// Percentile returns the p-th percentile (0-100) of sorted values using the nearest-rank method.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	if i >= len(sorted) {
+		i = len(sorted) - 1
 	}
 
-	loc = time.FixedZone(zone, offset)
-	return t.In(loc), err
+	return sorted[i]
 }
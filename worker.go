@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+const defaultConcurrency = 4
+
+// RunParallel calls fn for every index in [0, n) using at most concurrency goroutines.
+// Errors are collected in index order, so callers that store results by index
+// get deterministic output regardless of scheduling.
+func RunParallel(concurrency, n int, fn func(i int) error) error {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	if concurrency > n {
+		concurrency = n
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	var me MultiError
+	for _, err := range errs {
+		if err != nil {
+			me = append(me, err)
+		}
+	}
+
+	return me.ErrorOrNil()
+}
+
+// Section is a group of stats an input fetches with a single query or command.
+type Section struct {
+	Name  string
+	Fetch func() (map[string]float64, error)
+}
+
+// FetchSections fetches the sections in parallel.
+// The returned stats and errors are indexed like sections, the last value aggregates the errors.
+func FetchSections(concurrency int, sections []Section) ([]map[string]float64, []error, error) {
+	stats := make([]map[string]float64, len(sections))
+	errs := make([]error, len(sections))
+
+	err := RunParallel(concurrency, len(sections), func(i int) error {
+		stats[i], errs[i] = sections[i].Fetch()
+		if errs[i] != nil {
+			return fmt.Errorf("%s: %s", sections[i].Name, errs[i])
+		}
+
+		return nil
+	})
+
+	return stats, errs, err
+}
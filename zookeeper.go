@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ZooKeeper struct {
+	config ZooKeeperConfig
+	log    Logger
+}
+
+// ZooKeeperConfig sends the "mntr" and "ruok" four letter words, metrics are named zookeeper.<zk_* key>.
+// zk_server_state is reported as text and as zk_leader (1 on the leader, 0 otherwise),
+// zk_ruok is 1 if the server answered "imok". Every stat is reported if no Metrics are given.
+type ZooKeeperConfig struct {
+	Host    string                   `toml:"host"`
+	Port    int                      `toml:"port"`
+	Timeout int                      `toml:"timeout"`
+	Metrics []ZooKeeperMetricsConfig `toml:"metrics"`
+	TimestampConfig
+}
+
+type ZooKeeperMetricsConfig struct {
+	MetricsConfig
+}
+
+func NewZooKeeper(zkConfig ZooKeeperConfig, filename string, log Logger) (Input, error) {
+	var err error
+	var zk *ZooKeeper
+	var str string
+	str, err = LoadFile(filename)
+
+	if err != nil {
+		return zk, err
+	}
+
+	var config ZooKeeperConfig
+	_, err = toml.Decode(str, &config)
+
+	if err != nil {
+		return zk, err
+	}
+
+	if zkConfig.Host != "" {
+		config.Host = zkConfig.Host
+	}
+
+	if zkConfig.Port != 0 {
+		config.Port = zkConfig.Port
+	} else if config.Port == 0 {
+		config.Port = 2181
+	}
+
+	if zkConfig.Timeout > 0 {
+		config.Timeout = zkConfig.Timeout
+	}
+
+	for i := range config.Metrics {
+		if config.Metrics[i].Prefix == "" {
+			config.Metrics[i].Prefix = "zookeeper"
+		}
+	}
+
+	err = config.TimestampConfig.Setup()
+	if err != nil {
+		return zk, err
+	}
+
+	zk = &ZooKeeper{
+		config: config,
+		log:    log,
+	}
+
+	return zk, err
+}
+
+// command sends a four letter word, the server closes the connection after the response.
+func (zk *ZooKeeper) command(cmd string) ([]byte, error) {
+	timeout := time.Duration(zk.config.Timeout) * time.Second
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(zk.config.Host, strconv.Itoa(zk.config.Port)), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	_, err = io.WriteString(conn, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(conn)
+}
+
+// mntr parses the tab separated "zk_<key>\t<value>" lines, the server state is returned separately.
+func (zk *ZooKeeper) mntr() (map[string]float64, string, error) {
+	var state string
+	stats := make(map[string]float64)
+
+	buf, err := zk.command("mntr")
+	if err != nil {
+		return stats, state, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "zk_") {
+			continue
+		}
+
+		key, value := kv[0], strings.TrimSpace(kv[1])
+		if key == "zk_server_state" {
+			state = value
+			continue
+		}
+
+		fval, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		stats[key] = fval
+	}
+
+	// "mntr is not executed because it is not in the whitelist."
+	if len(stats) == 0 && state == "" {
+		return stats, state, fmt.Errorf("mntr: unexpected response: %s", strings.TrimSpace(string(buf)))
+	}
+
+	if state == "leader" {
+		stats["zk_leader"] = 1
+	} else {
+		stats["zk_leader"] = 0
+	}
+
+	return stats, state, scanner.Err()
+}
+
+func (zk *ZooKeeper) FetchMetrics() ([]Metric, error) {
+	now := ScheduledNow()
+	metrics := make([]Metric, 0)
+
+	stats, state, err := zk.mntr()
+	metrics = append(metrics, SectionMetrics("zookeeper", err, now)...)
+	if err != nil {
+		return zk.config.Apply(metrics, now), err
+	}
+
+	buf, ruokErr := zk.command("ruok")
+	if ruokErr != nil {
+		zk.log.Debug(ruokErr)
+	}
+
+	stats["zk_ruok"] = 0
+	if strings.TrimSpace(string(buf)) == "imok" {
+		stats["zk_ruok"] = 1
+	}
+
+	if len(zk.config.Metrics) == 0 {
+		for _, m := range AllMetrics(stats, now) {
+			m.Name = fmt.Sprintf("zookeeper.%s", m.Name)
+			metrics = append(metrics, m)
+		}
+
+		if state != "" {
+			metrics = append(metrics, Metric{
+				Name:  "zookeeper.zk_server_state",
+				Value: state,
+				Time:  now,
+			})
+		}
+	}
+
+	for _, m := range zk.config.Metrics {
+		metrics = append(metrics, m.Metrics(stats, now)...)
+
+		if name, ok := m.Select("zk_server_state"); ok && state != "" {
+			metrics = append(metrics, Metric{
+				Name:  name,
+				Value: state,
+				Time:  now,
+			})
+		}
+	}
+
+	return zk.config.Apply(metrics, now), err
+}
+
+func (zk *ZooKeeper) Teardown() {
+
+}
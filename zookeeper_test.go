@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+// serveZooKeeper answers four letter words from responses and closes the connection like ZooKeeper.
+func serveZooKeeper(t *testing.T, responses map[string]string) (net.Listener, ZooKeeperConfig) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				cmd := make([]byte, 4)
+				if _, err := io.ReadFull(conn, cmd); err != nil {
+					return
+				}
+				io.WriteString(conn, responses[string(cmd)])
+			}(conn)
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return ln, ZooKeeperConfig{Host: addr.IP.String(), Port: addr.Port, Timeout: 5}
+}
+
+func TestZooKeeperMntr(t *testing.T) {
+	tests := []struct {
+		name      string
+		mntr      string
+		wantState string
+		want      map[string]float64
+		wantErr   bool
+	}{
+		{
+			name: "leader",
+			mntr: "zk_version\t3.4.13-2d71af4dbe22557fda74f9a9b4309b15a7487f03, built on 06/29/2018 04:05 GMT\n" +
+				"zk_avg_latency\t0\n" +
+				"zk_outstanding_requests\t2\n" +
+				"zk_server_state\tleader\n" +
+				"zk_znode_count\t4\n" +
+				"zk_followers\t2\n",
+			wantState: "leader",
+			want: map[string]float64{
+				"zk_avg_latency":          0,
+				"zk_outstanding_requests": 2,
+				"zk_znode_count":          4,
+				"zk_followers":            2,
+				"zk_leader":               1,
+			},
+		},
+		{
+			name:      "follower",
+			mntr:      "zk_server_state\tfollower\nzk_num_alive_connections\t1\nnot_zk\t5\n",
+			wantState: "follower",
+			want: map[string]float64{
+				"zk_num_alive_connections": 1,
+				"zk_leader":                0,
+			},
+		},
+		{
+			name:    "not whitelisted",
+			mntr:    "mntr is not executed because it is not in the whitelist.\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty response",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, config := serveZooKeeper(t, map[string]string{"mntr": tt.mntr})
+			defer ln.Close()
+
+			zk := &ZooKeeper{config: config, log: NewLogger()}
+
+			stats, state, err := zk.mntr()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if state != tt.wantState {
+				t.Errorf("state = %q, want %q", state, tt.wantState)
+			}
+
+			if len(stats) != len(tt.want) {
+				t.Errorf("stats = %v, want %v", stats, tt.want)
+			}
+
+			for k, want := range tt.want {
+				if got, ok := stats[k]; !ok || got != want {
+					t.Errorf("%s = %v (found %v), want %v", k, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestZooKeeperFetchMetrics(t *testing.T) {
+	ln, config := serveZooKeeper(t, map[string]string{
+		"mntr": "zk_server_state\tstandalone\nzk_znode_count\t4\n",
+		"ruok": "imok",
+	})
+	defer ln.Close()
+
+	zk := &ZooKeeper{config: config, log: NewLogger()}
+
+	metrics, err := zk.FetchMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]interface{})
+	for _, m := range metrics {
+		got[m.Name] = m.Value
+	}
+
+	want := map[string]interface{}{
+		"zookeeper.up":              1.0,
+		"zookeeper.zk_znode_count":  4.0,
+		"zookeeper.zk_leader":       0.0,
+		"zookeeper.zk_ruok":         1.0,
+		"zookeeper.zk_server_state": "standalone",
+	}
+
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}